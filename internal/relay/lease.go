@@ -0,0 +1,97 @@
+// Package relay holds what the outbox relay and the scheduler dispatcher
+// share: publishing from a single instance elected with a Redis lock.
+package relay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+)
+
+// Lease elects the instance that publishes with a Redis lock.
+type Lease struct {
+	Client *redis.Client
+	Key    string
+	TTL    time.Duration
+	// RetryInterval is the pause between attempts to acquire the lock.
+	RetryInterval time.Duration
+	Logger        logging.Logger
+	// Name describes the publisher in log messages.
+	Name string
+}
+
+// Run acquires the lock and calls work while holding it, until ctx is
+// cancelled. While another instance holds the lock it retries at the retry
+// interval.
+//
+// The lock is refreshed in the background every third of its TTL, however
+// long work blocks. The context passed to work is cancelled as soon as a
+// refresh fails, before the lock can expire, so two instances never publish
+// at the same time.
+func (l *Lease) Run(ctx context.Context, work func(ctx context.Context) error) error {
+	for {
+		lock, err := l.Client.AcquireLock(ctx, l.Key, l.TTL)
+		if err != nil && ctx.Err() == nil {
+			l.Logger.Errorf("Failed to acquire lock %s of %s: %v", l.Key, l.Name, err)
+		}
+		if lock != nil {
+			if err := l.hold(ctx, lock, work); err != nil && ctx.Err() == nil {
+				l.Logger.Errorf("%s stopped: %v", l.Name, err)
+			}
+			if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+				l.Logger.Errorf("Failed to release lock %s of %s: %v", l.Key, l.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(l.RetryInterval):
+		}
+	}
+}
+
+// hold runs work while keeping lock alive. It returns the refresh error
+// when the lock was lost, and the error of work otherwise.
+func (l *Lease) hold(ctx context.Context, lock *redis.Lock, work func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	interval := l.TTL / 3
+	var refreshErr error
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// A refresh that takes longer than the interval may not complete
+			// before the lock expires.
+			refreshCtx, cancelRefresh := context.WithTimeout(ctx, interval)
+			err := lock.Refresh(refreshCtx, l.TTL)
+			cancelRefresh()
+			if err != nil && ctx.Err() == nil {
+				refreshErr = fmt.Errorf("failed to refresh lock %s: %w", l.Key, err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	err := work(ctx)
+	cancel()
+	<-refreshed
+	if refreshErr != nil {
+		return refreshErr
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestLease(t *testing.T, ttl time.Duration) (*Lease, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(redis.WithClusterOptions(&goredis.ClusterOptions{Addrs: []string{server.Addr()}}))
+	if err != nil {
+		t.Fatalf("failed to create redis client: %v", err)
+	}
+	return &Lease{
+		Client:        client,
+		Key:           "publisher-lock",
+		TTL:           ttl,
+		RetryInterval: 10 * time.Millisecond,
+		Logger:        &logging.NoOpLogger{},
+		Name:          "Test publisher",
+	}, server
+}
+
+func TestLease_RefreshesWhileWorking(t *testing.T) {
+	lease, server := newTestLease(t, 300*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	working := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- lease.Run(ctx, func(ctx context.Context) error {
+			close(working)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-working
+
+	// miniredis only expires keys when told to: bring the lock close to its
+	// expiration and wait for the background refresh to extend it.
+	server.FastForward(250 * time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	if ttl := server.TTL(lease.Key); ttl < 200*time.Millisecond {
+		t.Errorf("expected the lock to be refreshed, got ttl %v", ttl)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Exists(lease.Key) {
+		t.Error("expected the lock to be released")
+	}
+}
+
+func TestLease_CancelsWorkWhenLockIsLost(t *testing.T) {
+	lease, server := newTestLease(t, 300*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	working := make(chan struct{}, 1)
+	aborted := make(chan error, 1)
+	go lease.Run(ctx, func(workCtx context.Context) error {
+		working <- struct{}{}
+		<-workCtx.Done()
+		if ctx.Err() == nil {
+			aborted <- workCtx.Err()
+		}
+		return workCtx.Err()
+	})
+	<-working
+
+	// Another instance takes the lock over.
+	server.Set(lease.Key, "other-token")
+	select {
+	case err := <-aborted:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected work to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected work to be cancelled once the lock was lost")
+	}
+	if v, _ := server.Get(lease.Key); v != "other-token" {
+		t.Errorf("expected the other instance to keep the lock, got %q", v)
+	}
+}
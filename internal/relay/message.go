@@ -0,0 +1,36 @@
+package relay
+
+import (
+	"context"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+)
+
+// MessageOptions returns the options publishing a stored message with its
+// key and headers.
+func MessageOptions(key []byte, headers map[string]string) []kafka.MessageOption {
+	opts := make([]kafka.MessageOption, 0, 2)
+	if len(key) > 0 {
+		opts = append(opts, kafka.WithKey(key))
+	}
+	if len(headers) > 0 {
+		kafkaHeaders := make([]ckafka.Header, 0, len(headers))
+		for k, v := range headers {
+			kafkaHeaders = append(kafkaHeaders, ckafka.Header{Key: k, Value: []byte(v)})
+		}
+		opts = append(opts, kafka.WithMessageHeaders(kafkaHeaders...))
+	}
+	return opts
+}
+
+// Sleep pauses for d or until ctx is cancelled.
+func Sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
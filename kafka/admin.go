@@ -0,0 +1,403 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/samber/lo"
+)
+
+const defaultMetadataTimeout = 10 * time.Second
+
+// Common topic-level configs for TopicSpec.Config.
+const (
+	TopicConfigRetentionMs       = "retention.ms"
+	TopicConfigCleanupPolicy     = "cleanup.policy"
+	TopicConfigMinInSyncReplicas = "min.insync.replicas"
+)
+
+// TopicSpec declares the desired state of a topic. Zero Partitions and
+// ReplicationFactor fall back to the values of WithPartitions and
+// WithReplicationFactor when the topic is created, and leave existing topics
+// as they are.
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	// Config holds topic-level configs, e.g. TopicConfigRetentionMs. Configs
+	// not listed here are left untouched.
+	Config map[string]string
+}
+
+// TopicDescription is the current state of a topic.
+type TopicDescription struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Config            map[string]string
+}
+
+// ConfigChange is the current and desired value of a topic config.
+type ConfigChange struct {
+	Current string
+	Desired string
+}
+
+// TopicDiff is the difference between a TopicSpec and the topic's current
+// state.
+type TopicDiff struct {
+	Name string
+	// Missing is set when the topic does not exist yet.
+	Missing                  bool
+	CurrentPartitions        int
+	DesiredPartitions        int
+	CurrentReplicationFactor int
+	DesiredReplicationFactor int
+	Configs                  map[string]ConfigChange
+}
+
+// Empty reports whether the topic already matches its spec.
+func (d TopicDiff) Empty() bool {
+	return !d.Missing &&
+		d.CurrentPartitions == d.DesiredPartitions &&
+		d.CurrentReplicationFactor == d.DesiredReplicationFactor &&
+		len(d.Configs) == 0
+}
+
+// Admin administers the topics of a cluster.
+type Admin struct {
+	admin  *ckafka.AdminClient
+	cfg    *Config
+	logger logging.Logger
+}
+
+// NewAdmin creates an admin client from the same options as producers and
+// consumers.
+func NewAdmin(opts ...Option) (*Admin, error) {
+	return newAdmin(newConfig(opts...))
+}
+
+func newAdmin(cfg *Config) (*Admin, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers are required")
+	}
+
+	configMap := cfg.clientConfig()
+	admin, err := ckafka.NewAdminClient(&configMap)
+	if err != nil {
+		cfg.Logger.Errorf("Failed to create kafka admin client: %v", err)
+		return nil, err
+	}
+	return &Admin{admin: admin, cfg: cfg, logger: cfg.Logger}, nil
+}
+
+// newAdminFrom creates an admin client sharing the connection of a producer
+// or, when producer is nil, of a consumer.
+func newAdminFrom(cfg *Config, producer *ckafka.Producer, consumer *ckafka.Consumer) (*Admin, error) {
+	var admin *ckafka.AdminClient
+	var err error
+	if producer != nil {
+		admin, err = ckafka.NewAdminClientFromProducer(producer)
+	} else {
+		admin, err = ckafka.NewAdminClientFromConsumer(consumer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Admin{admin: admin, cfg: cfg, logger: cfg.Logger}, nil
+}
+
+// Close releases the admin client.
+func (a *Admin) Close() {
+	a.admin.Close()
+}
+
+// CreateTopics creates the given topics. Topics that already exist are
+// skipped; use Reconcile to bring them in line with their spec.
+func (a *Admin) CreateTopics(ctx context.Context, specs ...TopicSpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	topics := lo.Map(specs, func(spec TopicSpec, _ int) ckafka.TopicSpecification {
+		spec = a.withDefaults(spec)
+		return ckafka.TopicSpecification{
+			Topic:             spec.Name,
+			NumPartitions:     spec.Partitions,
+			ReplicationFactor: spec.ReplicationFactor,
+			Config:            spec.Config,
+		}
+	})
+
+	results, err := a.admin.CreateTopics(ctx, topics)
+	if err != nil {
+		return err
+	}
+	return topicResultsError(results, ckafka.ErrTopicAlreadyExists)
+}
+
+// DeleteTopics deletes the given topics. Topics that do not exist are
+// skipped.
+func (a *Admin) DeleteTopics(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	results, err := a.admin.DeleteTopics(ctx, names)
+	if err != nil {
+		return err
+	}
+	return topicResultsError(results, ckafka.ErrUnknownTopicOrPart)
+}
+
+// CreatePartitions increases the partition count of topic to total.
+func (a *Admin) CreatePartitions(ctx context.Context, topic string, total int) error {
+	results, err := a.admin.CreatePartitions(ctx, []ckafka.PartitionsSpecification{
+		{Topic: topic, IncreaseTo: total},
+	})
+	if err != nil {
+		return err
+	}
+	return topicResultsError(results)
+}
+
+// ListTopics returns the names of all topics in the cluster, sorted.
+func (a *Admin) ListTopics(ctx context.Context) ([]string, error) {
+	metadata, err := a.admin.GetMetadata(nil, true, timeoutMs(ctx, defaultMetadataTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	names := lo.Keys(metadata.Topics)
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeTopics returns the partition count, replication factor and configs
+// of the given topics. Topics that do not exist are omitted from the result.
+func (a *Admin) DescribeTopics(ctx context.Context, names ...string) ([]TopicDescription, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	result, err := a.admin.DescribeTopics(ctx, ckafka.NewTopicCollectionOfTopicNames(names))
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := make([]TopicDescription, 0, len(result.TopicDescriptions))
+	var errs []error
+	for _, d := range result.TopicDescriptions {
+		switch d.Error.Code() {
+		case ckafka.ErrNoError:
+		case ckafka.ErrUnknownTopicOrPart:
+			continue
+		default:
+			errs = append(errs, fmt.Errorf("topic %s: %w", d.Name, d.Error))
+			continue
+		}
+
+		description := TopicDescription{Name: d.Name, Partitions: len(d.Partitions)}
+		if len(d.Partitions) > 0 {
+			description.ReplicationFactor = len(d.Partitions[0].Replicas)
+		}
+		descriptions = append(descriptions, description)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(descriptions) == 0 {
+		return descriptions, nil
+	}
+
+	resources := lo.Map(descriptions, func(d TopicDescription, _ int) ckafka.ConfigResource {
+		return ckafka.ConfigResource{Type: ckafka.ResourceTopic, Name: d.Name}
+	})
+	configs, err := a.admin.DescribeConfigs(ctx, resources)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]ckafka.ConfigResourceResult, len(configs))
+	for _, c := range configs {
+		if c.Error.Code() != ckafka.ErrNoError {
+			errs = append(errs, fmt.Errorf("topic %s configs: %w", c.Name, c.Error))
+			continue
+		}
+		byName[c.Name] = c
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for i := range descriptions {
+		entries := byName[descriptions[i].Name].Config
+		descriptions[i].Config = make(map[string]string, len(entries))
+		for name, entry := range entries {
+			descriptions[i].Config[name] = entry.Value
+		}
+	}
+	return descriptions, nil
+}
+
+// Diff compares the given specs with the current state of their topics and
+// returns the non-empty differences.
+func (a *Admin) Diff(ctx context.Context, specs ...TopicSpec) ([]TopicDiff, error) {
+	names := lo.Map(specs, func(spec TopicSpec, _ int) string { return spec.Name })
+	descriptions, err := a.DescribeTopics(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+	current := lo.KeyBy(descriptions, func(d TopicDescription) string { return d.Name })
+
+	diffs := make([]TopicDiff, 0)
+	for _, spec := range specs {
+		var description *TopicDescription
+		if d, ok := current[spec.Name]; ok {
+			description = &d
+		}
+		if description == nil {
+			spec = a.withDefaults(spec)
+		}
+		if diff := diffTopic(spec, description); !diff.Empty() {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// Reconcile brings the given topics in line with their specs: missing topics
+// are created, partition counts increased and differing configs altered. It
+// returns the differences it found. Replication factor changes and partition
+// count decreases cannot be applied and are returned as errors.
+func (a *Admin) Reconcile(ctx context.Context, specs ...TopicSpec) ([]TopicDiff, error) {
+	diffs, err := a.Diff(ctx, specs...)
+	if err != nil {
+		return nil, err
+	}
+	byName := lo.KeyBy(specs, func(spec TopicSpec) string { return spec.Name })
+
+	var errs []error
+	var create []TopicSpec
+	var alter []ckafka.ConfigResource
+	for _, diff := range diffs {
+		if diff.Missing {
+			create = append(create, byName[diff.Name])
+			continue
+		}
+
+		switch {
+		case diff.DesiredPartitions > diff.CurrentPartitions:
+			if err := a.CreatePartitions(ctx, diff.Name, diff.DesiredPartitions); err != nil {
+				errs = append(errs, err)
+			}
+		case diff.DesiredPartitions < diff.CurrentPartitions:
+			errs = append(errs, fmt.Errorf("topic %s: cannot reduce partitions from %d to %d",
+				diff.Name, diff.CurrentPartitions, diff.DesiredPartitions))
+		}
+		if diff.DesiredReplicationFactor != diff.CurrentReplicationFactor {
+			errs = append(errs, fmt.Errorf("topic %s: cannot change replication factor from %d to %d",
+				diff.Name, diff.CurrentReplicationFactor, diff.DesiredReplicationFactor))
+		}
+
+		if len(diff.Configs) > 0 {
+			entries := make([]ckafka.ConfigEntry, 0, len(diff.Configs))
+			for name, change := range diff.Configs {
+				entries = append(entries, ckafka.ConfigEntry{
+					Name:                 name,
+					Value:                change.Desired,
+					IncrementalOperation: ckafka.AlterConfigOpTypeSet,
+				})
+			}
+			alter = append(alter, ckafka.ConfigResource{Type: ckafka.ResourceTopic, Name: diff.Name, Config: entries})
+		}
+	}
+
+	if err := a.CreateTopics(ctx, create...); err != nil {
+		errs = append(errs, err)
+	}
+	if len(alter) > 0 {
+		results, err := a.admin.IncrementalAlterConfigs(ctx, alter)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, r := range results {
+			if r.Error.Code() != ckafka.ErrNoError {
+				errs = append(errs, fmt.Errorf("topic %s configs: %w", r.Name, r.Error))
+			}
+		}
+	}
+
+	for _, diff := range diffs {
+		a.logger.Infof("Reconciled topic %s: %+v", diff.Name, diff)
+	}
+	return diffs, errors.Join(errs...)
+}
+
+func (a *Admin) withDefaults(spec TopicSpec) TopicSpec {
+	if spec.Partitions == 0 {
+		spec.Partitions = a.cfg.Partitions
+	}
+	if spec.ReplicationFactor == 0 {
+		spec.ReplicationFactor = a.cfg.ReplicationFactor
+	}
+	return spec
+}
+
+// diffTopic compares spec with the current description, nil when the topic
+// does not exist.
+func diffTopic(spec TopicSpec, current *TopicDescription) TopicDiff {
+	diff := TopicDiff{
+		Name:                     spec.Name,
+		DesiredPartitions:        spec.Partitions,
+		DesiredReplicationFactor: spec.ReplicationFactor,
+	}
+	if current == nil {
+		diff.Missing = true
+		return diff
+	}
+
+	diff.CurrentPartitions = current.Partitions
+	diff.CurrentReplicationFactor = current.ReplicationFactor
+	if diff.DesiredPartitions == 0 {
+		diff.DesiredPartitions = current.Partitions
+	}
+	if diff.DesiredReplicationFactor == 0 {
+		diff.DesiredReplicationFactor = current.ReplicationFactor
+	}
+	for name, desired := range spec.Config {
+		if actual := current.Config[name]; actual != desired {
+			if diff.Configs == nil {
+				diff.Configs = make(map[string]ConfigChange)
+			}
+			diff.Configs[name] = ConfigChange{Current: actual, Desired: desired}
+		}
+	}
+	return diff
+}
+
+// topicResultsError joins the errors of results, ignoring the given codes.
+func topicResultsError(results []ckafka.TopicResult, ignore ...ckafka.ErrorCode) error {
+	var errs []error
+	for _, r := range results {
+		code := r.Error.Code()
+		if code == ckafka.ErrNoError || lo.Contains(ignore, code) {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("topic %s: %w", r.Topic, r.Error))
+	}
+	return errors.Join(errs...)
+}
+
+// timeoutMs returns the time left until the deadline of ctx, or fallback
+// when ctx has none.
+func timeoutMs(ctx context.Context, fallback time.Duration) int {
+	if deadline, ok := ctx.Deadline(); ok {
+		return int(time.Until(deadline) / time.Millisecond)
+	}
+	return int(fallback / time.Millisecond)
+}
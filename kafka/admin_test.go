@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/samber/lo"
+)
+
+func TestDiffTopic(t *testing.T) {
+	spec := TopicSpec{
+		Name:       "orders",
+		Partitions: 12,
+		Config: map[string]string{
+			TopicConfigRetentionMs:   "86400000",
+			TopicConfigCleanupPolicy: "delete",
+		},
+	}
+
+	missing := diffTopic(spec, nil)
+	if !missing.Missing || missing.Empty() {
+		t.Errorf("expected missing topic diff, got %+v", missing)
+	}
+
+	diff := diffTopic(spec, &TopicDescription{
+		Name:              "orders",
+		Partitions:        6,
+		ReplicationFactor: 3,
+		Config: map[string]string{
+			TopicConfigRetentionMs:   "604800000",
+			TopicConfigCleanupPolicy: "delete",
+		},
+	})
+	if diff.CurrentPartitions != 6 || diff.DesiredPartitions != 12 {
+		t.Errorf("expected partitions 6 -> 12, got %d -> %d", diff.CurrentPartitions, diff.DesiredPartitions)
+	}
+	if diff.DesiredReplicationFactor != 3 {
+		t.Errorf("expected unspecified replication factor to keep current, got %d", diff.DesiredReplicationFactor)
+	}
+	if len(diff.Configs) != 1 {
+		t.Fatalf("expected 1 config change, got %v", diff.Configs)
+	}
+	if change := diff.Configs[TopicConfigRetentionMs]; change.Current != "604800000" || change.Desired != "86400000" {
+		t.Errorf("unexpected retention change: %+v", change)
+	}
+
+	same := diffTopic(TopicSpec{Name: "orders"}, &TopicDescription{Name: "orders", Partitions: 6, ReplicationFactor: 3})
+	if !same.Empty() {
+		t.Errorf("expected empty diff, got %+v", same)
+	}
+}
+
+func TestTopicResultsError(t *testing.T) {
+	results := []ckafka.TopicResult{
+		{Topic: "a", Error: ckafka.NewError(ckafka.ErrNoError, "", false)},
+		{Topic: "b", Error: ckafka.NewError(ckafka.ErrTopicAlreadyExists, "exists", false)},
+	}
+	if err := topicResultsError(results, ckafka.ErrTopicAlreadyExists); err != nil {
+		t.Errorf("expected ignored codes to pass, got %v", err)
+	}
+	if err := topicResultsError(results); err == nil {
+		t.Error("expected error for topic b")
+	}
+}
+
+func TestAdmin_MockCluster(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+
+	admin, err := NewAdmin(WithBrokers([]string{cluster.BootstrapServers()}), WithReplicationFactor(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := cluster.CreateTopic("orders", 3, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	topics, err := admin.ListTopics(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error listing topics: %v", err)
+	}
+	if !lo.Contains(topics, "orders") {
+		t.Errorf("expected orders to be listed, got %v", topics)
+	}
+}
@@ -0,0 +1,195 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultBatchTimeout = time.Second
+)
+
+// BatchHandler processes a batch of decoded messages. Returning an error
+// prevents the offsets of the whole batch from being committed.
+type BatchHandler[T any] func(ctx context.Context, msgs []*Message[T]) error
+
+type batchOptions struct {
+	size         int
+	timeout      time.Duration
+	retryBackoff time.Duration
+}
+
+type BatchOption func(*batchOptions)
+
+// WithBatchSize sets the maximum number of messages in a batch, 100 by
+// default.
+func WithBatchSize(size int) BatchOption {
+	return func(o *batchOptions) {
+		o.size = size
+	}
+}
+
+// WithBatchTimeout sets how long a batch waits to fill up after its first
+// message before it is handled anyway, 1s by default.
+func WithBatchTimeout(timeout time.Duration) BatchOption {
+	return func(o *batchOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithBatchRetryBackoff sets the pause before a failed batch is redelivered.
+func WithBatchRetryBackoff(backoff time.Duration) BatchOption {
+	return func(o *batchOptions) {
+		o.retryBackoff = backoff
+	}
+}
+
+// BatchConsumer consumes a topic in batches: messages are collected until the
+// batch size is reached or the batch timeout expires, whichever comes first,
+// decoded into T and handed to a BatchHandler together.
+//
+// The offsets of a batch are committed once, after the handler succeeds.
+// When the handler fails the whole batch is redelivered after the retry
+// backoff. Messages that cannot be decoded are logged and left out of the
+// batch.
+type BatchConsumer[T any] struct {
+	cm      *ConsumerManager
+	topic   string
+	handler BatchHandler[T]
+	opts    *batchOptions
+	logger  logging.Logger
+}
+
+// NewBatchConsumer creates a batch consumer for topic. Group ID, brokers and
+// logger are taken from the consumer manager's configuration.
+func NewBatchConsumer[T any](cm *ConsumerManager, topic string, handler BatchHandler[T], opts ...BatchOption) *BatchConsumer[T] {
+	options := &batchOptions{
+		size:         defaultBatchSize,
+		timeout:      defaultBatchTimeout,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &BatchConsumer[T]{
+		cm:      cm,
+		topic:   topic,
+		handler: handler,
+		opts:    options,
+		logger:  cm.logger,
+	}
+}
+
+// Run consumes the topic until ctx is cancelled. The batch being handled when
+// ctx is cancelled is allowed to finish; the consumer is rewound to the
+// messages collected for the next batch, which are not committed, so they
+// are consumed again by its next borrower. Run only returns an error when no
+// consumer can be borrowed or the consumer hits a fatal error, in which case
+// the consumer is closed instead of being given back to the pool.
+func (b *BatchConsumer[T]) Run(ctx context.Context) error {
+	pool := b.cm.getPool(b.topic, false)
+	consumer, release, err := pool.BorrowReader()
+	if err != nil {
+		return fmt.Errorf("failed to borrow consumer for topic %s: %w", b.topic, err)
+	}
+
+	for {
+		raws, err := b.collect(ctx, consumer)
+		if err != nil {
+			pool.discard(consumer)
+			return err
+		}
+		if ctx.Err() != nil {
+			if b.rewind(consumer, raws) {
+				release()
+			} else {
+				pool.discard(consumer)
+			}
+			return nil
+		}
+		b.process(ctx, consumer, raws)
+	}
+}
+
+// rewind seeks the consumer back to the first of raws. It reports false when
+// some partition could not be rewound, in which case the consumer must not be
+// reused.
+func (b *BatchConsumer[T]) rewind(consumer Reader, raws []*ckafka.Message) bool {
+	if len(raws) == 0 {
+		return true
+	}
+	if err := seekPartitions(consumer, firstOffsets(raws)); err != nil {
+		b.logger.Errorf("Failed to rewind to uncommitted messages on topic %s, closing the consumer: %v", b.topic, err)
+		return false
+	}
+	return true
+}
+
+// collect polls until the batch is full, the batch timeout has passed since
+// its first message or ctx is done.
+func (b *BatchConsumer[T]) collect(ctx context.Context, consumer Reader) ([]*ckafka.Message, error) {
+	raws := make([]*ckafka.Message, 0, b.opts.size)
+	var deadline time.Time
+	for len(raws) < b.opts.size {
+		if ctx.Err() != nil {
+			return raws, nil
+		}
+
+		timeout := defaultPollTimeout
+		if len(raws) > 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				break
+			}
+			timeout = min(timeout, remaining)
+		}
+
+		msg, err := readMessage(consumer, timeout)
+		switch {
+		case err != nil && isFatal(err):
+			b.logger.Errorf("Fatal consumer error on topic %s: %v", b.topic, err)
+			return nil, err
+		case err != nil:
+			b.logger.Errorf("Consumer error on topic %s: %v", b.topic, err)
+		case msg != nil:
+			if len(raws) == 0 {
+				deadline = time.Now().Add(b.opts.timeout)
+			}
+			raws = append(raws, msg)
+		}
+	}
+	return raws, nil
+}
+
+func (b *BatchConsumer[T]) process(ctx context.Context, consumer Reader, raws []*ckafka.Message) {
+	msgs := make([]*Message[T], 0, len(raws))
+	for _, raw := range raws {
+		value, err := decodeValue[T](b.cm.cfg, raw)
+		if err != nil {
+			b.logger.Errorf("Skipping message %s: %v", raw.TopicPartition, &decodeError{err: err})
+			continue
+		}
+		msgs = append(msgs, &Message[T]{Value: value, Raw: raw})
+	}
+
+	if len(msgs) > 0 {
+		if err := b.handler(ctx, msgs); err != nil {
+			b.logger.Errorf("Failed to handle batch of %d messages on topic %s: %v", len(msgs), b.topic, err)
+			if _, err := consumer.SeekPartitions(firstOffsets(raws)); err != nil {
+				b.logger.Errorf("Failed to seek back to batch on topic %s: %v", b.topic, err)
+			}
+			sleep(ctx, b.opts.retryBackoff)
+			return
+		}
+	}
+
+	if _, err := consumer.CommitOffsets(nextOffsets(raws)); err != nil {
+		b.logger.Errorf("Failed to commit batch on topic %s: %v", b.topic, err)
+	}
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestBatchConsumer_Run(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("events", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := NewProducer(brokers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		if _, err := producer.SendSync(ctx, "events", []byte("event")); err != nil {
+			t.Fatalf("unexpected error sending: %v", err)
+		}
+	}
+
+	cm := NewConsumerManager(brokers, WithGroupID("batch-group"))
+	defer cm.Close(context.Background())
+
+	var mu sync.Mutex
+	var sizes []int
+	total := 0
+	failed := false
+	runCtx, stop := context.WithCancel(ctx)
+	consumer := NewBatchConsumer(cm, "events", func(ctx context.Context, msgs []*Message[[]byte]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed {
+			failed = true
+			return context.DeadlineExceeded
+		}
+		sizes = append(sizes, len(msgs))
+		total += len(msgs)
+		if total == 5 {
+			stop()
+		}
+		return nil
+	}, WithBatchSize(2), WithBatchTimeout(100*time.Millisecond), WithBatchRetryBackoff(10*time.Millisecond))
+
+	if err := consumer.Run(runCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != 5 {
+		t.Fatalf("expected 5 messages after the failed batch was redelivered, got %d in %v", total, sizes)
+	}
+	for _, size := range sizes {
+		if size > 2 {
+			t.Errorf("expected batches of at most 2 messages, got %v", sizes)
+		}
+	}
+
+	lag, err := cm.Lag(ctx, "events")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lag.Total() != 0 || lag.Partitions[0].Committed != 5 {
+		t.Errorf("expected all batches to be committed, got %+v", lag.Partitions)
+	}
+}
+
+func TestBatchConsumer_RunRewindsOnCancel(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("events", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := NewProducer(brokers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if _, err := producer.SendSync(ctx, "events", []byte("event")); err != nil {
+			t.Fatalf("unexpected error sending: %v", err)
+		}
+	}
+
+	assigned := make(chan struct{}, 1)
+	cm := NewConsumerManager(brokers, WithGroupID("batch-group"),
+		WithRebalanceCallback(func(_ *ckafka.Consumer, ev ckafka.Event) error {
+			if _, ok := ev.(ckafka.AssignedPartitions); ok {
+				select {
+				case assigned <- struct{}{}:
+				default:
+				}
+			}
+			return nil
+		}))
+	defer cm.Close(context.Background())
+
+	// The first run collects the messages into a batch that never fills up
+	// and is cancelled before handling it.
+	runCtx, stop := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-assigned:
+			time.Sleep(time.Second)
+		case <-ctx.Done():
+		}
+		stop()
+	}()
+	unhandled := NewBatchConsumer(cm, "events", func(ctx context.Context, msgs []*Message[[]byte]) error {
+		t.Errorf("expected the unfilled batch not to be handled, got %d messages", len(msgs))
+		return nil
+	}, WithBatchSize(10), WithBatchTimeout(time.Minute))
+	if err := unhandled.Run(runCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The second run borrows the same consumer and must see them again.
+	var offsets []ckafka.Offset
+	runCtx, stop = context.WithCancel(ctx)
+	defer stop()
+	handled := NewBatchConsumer(cm, "events", func(ctx context.Context, msgs []*Message[[]byte]) error {
+		for _, msg := range msgs {
+			offsets = append(offsets, msg.Raw.TopicPartition.Offset)
+		}
+		if len(offsets) == 3 {
+			stop()
+		}
+		return nil
+	}, WithBatchSize(3), WithBatchTimeout(100*time.Millisecond))
+	if err := handled.Run(runCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(offsets) != 3 || offsets[0] != 0 {
+		t.Errorf("expected the 3 collected messages to be consumed again, got offsets %v", offsets)
+	}
+}
+
+func TestBatchConsumer_DiscardsConsumerOnFatalError(t *testing.T) {
+	cm, reader := newFatalManager()
+	batch := NewBatchConsumer(cm, "orders", func(ctx context.Context, msgs []*Message[[]byte]) error {
+		return nil
+	})
+
+	var kafkaErr ckafka.Error
+	if err := batch.Run(context.Background()); !errors.As(err, &kafkaErr) || !kafkaErr.IsFatal() {
+		t.Fatalf("expected the fatal error, got %v", err)
+	}
+	if !reader.closed {
+		t.Error("expected the consumer to be closed instead of returned to the pool")
+	}
+}
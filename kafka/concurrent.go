@@ -0,0 +1,302 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const defaultMaxInFlight = 1000
+
+// WithConcurrency handles messages with the given number of workers instead
+// of in the poll loop. Messages of the same partition, or of the same key with
+// WithKeyOrdering, always go to the same worker and are handled in order.
+// Offsets are only committed past messages that completed contiguously, so a
+// slow message holds back the commits of its partition but not the handling
+// of other partitions.
+//
+// Partitions and keys are spread over the workers by hash, so several of
+// them share a worker and wait behind each other's messages. A failed message
+// is retried in place after the retry backoff, or forwarded when a
+// RetryPolicy is set. Without a RetryPolicy a message that keeps failing is
+// retried indefinitely and stalls every partition or key of its worker; set
+// one to bound how long a failure holds the worker.
+func WithConcurrency(workers int) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.concurrency = workers
+	}
+}
+
+// WithMaxInFlight bounds the number of messages polled but not yet handled in
+// concurrent mode, 1000 by default. Polling pauses while the limit is
+// reached.
+func WithMaxInFlight(n int) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.maxInFlight = n
+	}
+}
+
+// WithKeyOrdering orders messages by key rather than by partition in
+// concurrent mode, so messages of one partition with different keys are
+// handled in parallel. Messages without a key are ordered by partition.
+func WithKeyOrdering() SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.keyOrdering = true
+	}
+}
+
+// completion reports a message handed to a worker as finished. done is false
+// when the worker gave up on the message because the subscriber is stopping.
+type completion struct {
+	msg  *ckafka.Message
+	done bool
+}
+
+// consumeConcurrently polls topic and dispatches the messages to workers. It
+// commits the offsets the tracker allows after every poll, and once more
+// after the in-flight messages have drained on shutdown. Partitions with
+// messages left unhandled are then rewound to the first of them; it reports
+// false when that failed and the consumer must not be reused.
+func (s *Subscriber[T]) consumeConcurrently(ctx context.Context, pool *ConsumerPool, consumer Reader, topic string, delayed delayedPartitions) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	maxInFlight := max(s.opts.maxInFlight, 1)
+	completions := make(chan completion, maxInFlight)
+	queues := make([]chan *ckafka.Message, s.opts.concurrency)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *ckafka.Message, maxInFlight)
+		wg.Add(1)
+		go func(queue <-chan *ckafka.Message) {
+			defer wg.Done()
+			for msg := range queue {
+				completions <- completion{msg: msg, done: ctx.Err() == nil && s.processInPlace(ctx, msg)}
+			}
+		}(queues[i])
+	}
+
+	tracker := newOffsetTracker()
+	// Offsets of revoked partitions belong to their new owner.
+	defer pool.onRevoke(consumer, tracker.revoke)()
+	inFlight := 0
+	complete := func(c completion) {
+		inFlight--
+		if c.done {
+			tracker.done(c.msg)
+		}
+	}
+	commit := func() {
+		offsets := tracker.committable()
+		if len(offsets) == 0 {
+			return
+		}
+		if _, err := consumer.CommitOffsets(offsets); err != nil {
+			s.logger.Errorf("Failed to commit offsets %v: %v", offsets, err)
+		}
+	}
+
+	var fatalErr error
+	for ctx.Err() == nil {
+	drain:
+		for {
+			select {
+			case c := <-completions:
+				complete(c)
+			default:
+				break drain
+			}
+		}
+		commit()
+
+		if inFlight >= maxInFlight {
+			select {
+			case c := <-completions:
+				complete(c)
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		msg, err := s.poll(consumer, topic, delayed)
+		if err != nil {
+			fatalErr = err
+			break
+		}
+		if msg == nil {
+			continue
+		}
+		tracker.add(msg)
+		inFlight++
+		queues[s.worker(msg)] <- msg
+	}
+
+	cancel()
+	for _, queue := range queues {
+		close(queue)
+	}
+	for inFlight > 0 {
+		complete(<-completions)
+	}
+	wg.Wait()
+	commit()
+
+	if offsets := tracker.uncommitted(); len(offsets) > 0 && fatalErr == nil {
+		if err := seekPartitions(consumer, offsets); err != nil {
+			s.logger.Errorf("Failed to rewind to uncommitted messages on topic %s, closing the consumer: %v", topic, err)
+			return false, nil
+		}
+	}
+	return fatalErr == nil, fatalErr
+}
+
+// processInPlace handles msg until it succeeds, is forwarded by the retry
+// policy or is skipped because it cannot be decoded. It reports false when
+// ctx is done before that.
+func (s *Subscriber[T]) processInPlace(ctx context.Context, msg *ckafka.Message) bool {
+	for {
+		err := s.handle(ctx, msg)
+		if err == nil {
+			return true
+		}
+
+		var decodeErr *decodeError
+		retryable := !errors.As(err, &decodeErr)
+		s.logger.Errorf("Failed to handle message %s: %v", msg.TopicPartition, err)
+
+		if s.opts.retry == nil && !retryable {
+			return true
+		}
+		if s.opts.retry != nil && s.forward(ctx, msg, err, retryable) {
+			return true
+		}
+
+		sleep(ctx, s.opts.retryBackoff)
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+}
+
+// worker returns the index of the worker that handles msg.
+func (s *Subscriber[T]) worker(msg *ckafka.Message) int {
+	h := fnv.New32a()
+	if s.opts.keyOrdering && len(msg.Key) > 0 {
+		_, _ = h.Write(msg.Key)
+	} else {
+		_, _ = h.Write([]byte(partitionKey(msg.TopicPartition)))
+	}
+	return int(h.Sum32() % uint32(s.opts.concurrency))
+}
+
+func partitionKey(tp ckafka.TopicPartition) string {
+	return fmt.Sprintf("%s/%d", *tp.Topic, tp.Partition)
+}
+
+// offsetTracker tracks the messages in flight per partition and the offset
+// that can be committed: the one following the last message of an unbroken
+// run of completed messages.
+type offsetTracker struct {
+	partitions map[string]*trackedPartition
+}
+
+type trackedPartition struct {
+	topic *string
+	id    int32
+	// pending holds the in-flight offsets in ascending order.
+	pending []trackedOffset
+	// next is the offset to commit, -1 until a message completed.
+	next    ckafka.Offset
+	changed bool
+}
+
+type trackedOffset struct {
+	msg  *ckafka.Message
+	done bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[string]*trackedPartition)}
+}
+
+// add records a message as in flight. Messages are added in the order they
+// are polled; an offset at or below the last one added means the partition
+// was rewound, e.g. after being revoked and assigned again, and the messages
+// still in flight on it are forgotten.
+func (t *offsetTracker) add(msg *ckafka.Message) {
+	tp := msg.TopicPartition
+	key := partitionKey(tp)
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &trackedPartition{topic: tp.Topic, id: tp.Partition, next: -1}
+		t.partitions[key] = p
+	}
+	if n := len(p.pending); n > 0 && tp.Offset <= p.pending[n-1].msg.TopicPartition.Offset {
+		p.pending = nil
+	}
+	p.pending = append(p.pending, trackedOffset{msg: msg})
+}
+
+// done marks a message as completed. Messages that are not in flight, such
+// as those forgotten by add, are ignored.
+func (t *offsetTracker) done(msg *ckafka.Message) {
+	tp := msg.TopicPartition
+	p, ok := t.partitions[partitionKey(tp)]
+	if !ok {
+		return
+	}
+	i := sort.Search(len(p.pending), func(i int) bool { return p.pending[i].msg.TopicPartition.Offset >= tp.Offset })
+	if i == len(p.pending) || p.pending[i].msg != msg {
+		return
+	}
+	p.pending[i].done = true
+
+	completed := 0
+	for completed < len(p.pending) && p.pending[completed].done {
+		completed++
+	}
+	if completed > 0 {
+		p.next = p.pending[completed-1].msg.TopicPartition.Offset + 1
+		p.changed = true
+		p.pending = p.pending[completed:]
+	}
+}
+
+// committable returns the offsets that advanced since the last call.
+func (t *offsetTracker) committable() []ckafka.TopicPartition {
+	var offsets []ckafka.TopicPartition
+	for _, p := range t.partitions {
+		if !p.changed {
+			continue
+		}
+		p.changed = false
+		offsets = append(offsets, ckafka.TopicPartition{Topic: p.topic, Partition: p.id, Offset: p.next})
+	}
+	return offsets
+}
+
+// revoke forgets partitions, so no offset is committed for them any more and
+// the completions of their in-flight messages are ignored.
+func (t *offsetTracker) revoke(partitions []ckafka.TopicPartition) {
+	for _, tp := range partitions {
+		delete(t.partitions, partitionKey(tp))
+	}
+}
+
+// uncommitted returns, per partition, the offset of the first message still
+// in flight.
+func (t *offsetTracker) uncommitted() []ckafka.TopicPartition {
+	var offsets []ckafka.TopicPartition
+	for _, p := range t.partitions {
+		if len(p.pending) == 0 {
+			continue
+		}
+		offsets = append(offsets, ckafka.TopicPartition{Topic: p.topic, Partition: p.id, Offset: p.pending[0].msg.TopicPartition.Offset})
+	}
+	return offsets
+}
@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestOffsetTracker_CommitsContiguousOffsets(t *testing.T) {
+	tracker := newOffsetTracker()
+	msgs := []*ckafka.Message{
+		testMessage("orders", 0, 10),
+		testMessage("orders", 0, 11),
+		testMessage("orders", 0, 12),
+		testMessage("orders", 1, 5),
+	}
+	for _, msg := range msgs {
+		tracker.add(msg)
+	}
+
+	tracker.done(msgs[1])
+	tracker.done(msgs[2])
+	if offsets := tracker.committable(); len(offsets) != 0 {
+		t.Fatalf("expected nothing to commit while offset 10 is in flight, got %v", offsets)
+	}
+
+	tracker.done(msgs[0])
+	tracker.done(msgs[3])
+	offsets := tracker.committable()
+	if len(offsets) != 2 {
+		t.Fatalf("expected offsets for 2 partitions, got %v", offsets)
+	}
+	for _, tp := range offsets {
+		want := map[int32]ckafka.Offset{0: 13, 1: 6}[tp.Partition]
+		if tp.Offset != want {
+			t.Errorf("expected offset %d on partition %d, got %d", want, tp.Partition, tp.Offset)
+		}
+	}
+
+	if offsets := tracker.committable(); len(offsets) != 0 {
+		t.Errorf("expected committed offsets to be returned once, got %v", offsets)
+	}
+}
+
+func TestOffsetTracker_Rewind(t *testing.T) {
+	tracker := newOffsetTracker()
+	stale := testMessage("orders", 0, 10)
+	tracker.add(stale)
+	tracker.add(testMessage("orders", 0, 11))
+
+	redelivered := testMessage("orders", 0, 10)
+	tracker.add(redelivered)
+
+	tracker.done(stale)
+	if offsets := tracker.committable(); len(offsets) != 0 {
+		t.Fatalf("expected stale message to be ignored, got %v", offsets)
+	}
+
+	tracker.done(redelivered)
+	offsets := tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 11 {
+		t.Errorf("expected offset 11, got %v", offsets)
+	}
+}
+
+func TestSubscriber_ConcurrentKeepsPartitionOrder(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("orders", 4, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := NewProducer(brokers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	const total = 40
+	for i := 0; i < total; i++ {
+		if _, err := producer.SendSync(ctx, "orders", []byte(fmt.Sprint(i)), WithPartition(int32(i%4))); err != nil {
+			t.Fatalf("unexpected error sending: %v", err)
+		}
+	}
+
+	cm := NewConsumerManager(brokers, WithGroupID("concurrent-group"))
+	defer cm.Close(context.Background())
+
+	var mu sync.Mutex
+	last := make(map[int32]ckafka.Offset)
+	handled := 0
+	runCtx, stop := context.WithCancel(ctx)
+	sub := NewSubscriber(cm, "orders", func(ctx context.Context, msg *Message[[]byte]) error {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		tp := msg.Raw.TopicPartition
+		if prev, ok := last[tp.Partition]; ok && tp.Offset <= prev {
+			t.Errorf("partition %d: offset %d handled after %d", tp.Partition, tp.Offset, prev)
+		}
+		last[tp.Partition] = tp.Offset
+		if handled++; handled == total {
+			stop()
+		}
+		return nil
+	}, WithConcurrency(4), WithMaxInFlight(8))
+
+	if err := sub.Run(runCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handled != total {
+		t.Fatalf("expected %d messages, got %d", total, handled)
+	}
+
+	lag, err := cm.Lag(ctx, "orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lag.Total() != 0 {
+		t.Errorf("expected all offsets to be committed, got %+v", lag.Partitions)
+	}
+}
+
+func TestOffsetTracker_Revoke(t *testing.T) {
+	tracker := newOffsetTracker()
+	revoked := testMessage("orders", 0, 10)
+	kept := testMessage("orders", 1, 5)
+	tracker.add(revoked)
+	tracker.add(kept)
+
+	tracker.revoke([]ckafka.TopicPartition{revoked.TopicPartition})
+	tracker.done(revoked)
+	tracker.done(kept)
+	offsets := tracker.committable()
+	if len(offsets) != 1 || offsets[0].Partition != 1 || offsets[0].Offset != 6 {
+		t.Errorf("expected only partition 1 to be committed, got %v", offsets)
+	}
+}
+
+func TestOffsetTracker_Uncommitted(t *testing.T) {
+	tracker := newOffsetTracker()
+	msgs := []*ckafka.Message{
+		testMessage("orders", 0, 10),
+		testMessage("orders", 0, 11),
+		testMessage("orders", 0, 12),
+		testMessage("orders", 1, 5),
+	}
+	for _, msg := range msgs {
+		tracker.add(msg)
+	}
+	tracker.done(msgs[0])
+	tracker.done(msgs[2])
+	tracker.done(msgs[3])
+
+	offsets := tracker.uncommitted()
+	if len(offsets) != 1 || offsets[0].Partition != 0 || offsets[0].Offset != 11 {
+		t.Errorf("expected partition 0 to be rewound to offset 11, got %v", offsets)
+	}
+}
+
+func TestSubscriber_ConcurrentRewindsOnShutdown(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("orders", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := NewProducer(brokers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if _, err := producer.SendSync(ctx, "orders", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("unexpected error sending: %v", err)
+		}
+	}
+
+	cm := NewConsumerManager(brokers, WithGroupID("concurrent-group"))
+	defer cm.Close(context.Background())
+
+	// The first run handles offset 0 and is stopped while offset 1 is in
+	// flight and offset 2 is queued behind it.
+	runCtx, stop := context.WithCancel(ctx)
+	sub := NewSubscriber(cm, "orders", func(ctx context.Context, msg *Message[[]byte]) error {
+		if msg.Raw.TopicPartition.Offset == 0 {
+			return nil
+		}
+		stop()
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithConcurrency(2), WithRetryBackoff(10*time.Millisecond))
+	if err := sub.Run(runCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The second run reuses the consumer and must start at offset 1.
+	var offsets []ckafka.Offset
+	runCtx, stop = context.WithTimeout(ctx, 10*time.Second)
+	defer stop()
+	sub = NewSubscriber(cm, "orders", func(ctx context.Context, msg *Message[[]byte]) error {
+		offsets = append(offsets, msg.Raw.TopicPartition.Offset)
+		if len(offsets) == 2 {
+			stop()
+		}
+		return nil
+	}, WithConcurrency(2))
+	if err := sub.Run(runCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(offsets) != 2 || offsets[0] != 1 || offsets[1] != 2 {
+		t.Errorf("expected the unhandled offsets 1 and 2 to be consumed again, got %v", offsets)
+	}
+}
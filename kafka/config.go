@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"strings"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// SecurityConfig holds the connection security settings shared by all
+// clients.
+//
+// When Protocol is empty it is derived from the other settings: SASL_SSL if
+// a SASL mechanism is set, SSL if only a CA file is set, and the librdkafka
+// default (PLAINTEXT) otherwise.
+type SecurityConfig struct {
+	Protocol      string
+	SASLMechanism string
+	Username      string
+	Password      string
+	CAFile        string
+}
+
+func (s SecurityConfig) protocol() string {
+	switch {
+	case s.Protocol != "":
+		return s.Protocol
+	case s.SASLMechanism != "":
+		return "SASL_SSL"
+	case s.CAFile != "":
+		return "SSL"
+	default:
+		return ""
+	}
+}
+
+func (s SecurityConfig) apply(configMap ckafka.ConfigMap) {
+	if protocol := s.protocol(); protocol != "" {
+		configMap["security.protocol"] = protocol
+	}
+	if s.SASLMechanism != "" {
+		configMap["sasl.mechanism"] = s.SASLMechanism
+		configMap["sasl.username"] = s.Username
+		configMap["sasl.password"] = s.Password
+	}
+	if s.CAFile != "" {
+		configMap["ssl.ca.location"] = s.CAFile
+	}
+}
+
+// ClientConfig returns the librdkafka properties shared by every client
+// built from opts: brokers, client ID, security settings and WithConfigMap.
+// It lets other kafka clients, such as the logging.KafkaCore, connect to the
+// same cluster the same way.
+func ClientConfig(opts ...Option) ckafka.ConfigMap {
+	return newConfig(opts...).clientConfig()
+}
+
+func (c *Config) clientConfig() ckafka.ConfigMap {
+	configMap := ckafka.ConfigMap{
+		"bootstrap.servers": strings.Join(c.Brokers, ","),
+		"client.id":         c.ClientID,
+	}
+	c.Security.apply(configMap)
+	return mergeConfigMaps(configMap, c.ConfigMap)
+}
+
+func (c *Config) producerConfig() *ckafka.ConfigMap {
+	configMap := c.clientConfig()
+	if c.Idempotence {
+		configMap["enable.idempotence"] = true
+	}
+	if c.TransactionalID != "" {
+		configMap["transactional.id"] = c.TransactionalID
+	}
+	configMap = mergeConfigMaps(configMap, c.ProducerConfigMap)
+	return &configMap
+}
+
+// consumerConfig returns the consumer properties. group.id and
+// enable.auto.commit are owned by the pool and cannot be overridden.
+func (c *Config) consumerConfig(groupID string, autoCommit bool) *ckafka.ConfigMap {
+	configMap := c.clientConfig()
+	configMap["auto.offset.reset"] = c.AutoOffsetReset
+	configMap = mergeConfigMaps(configMap, c.ConsumerConfigMap)
+	configMap["group.id"] = groupID
+	configMap["enable.auto.commit"] = autoCommit
+	return &configMap
+}
+
+// mergeConfigMaps returns a copy of base with the properties of overrides
+// applied on top.
+func mergeConfigMaps(base ckafka.ConfigMap, overrides ckafka.ConfigMap) ckafka.ConfigMap {
+	merged := make(ckafka.ConfigMap, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
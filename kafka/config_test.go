@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"testing"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestClientConfig_Security(t *testing.T) {
+	cfg := ClientConfig(
+		WithBrokers([]string{"b1:9092", "b2:9092"}),
+		WithSASL("SCRAM-SHA-512", "user", "secret"),
+		WithCAFile("/etc/ssl/ca.pem"),
+	)
+
+	expected := ckafka.ConfigMap{
+		"bootstrap.servers": "b1:9092,b2:9092",
+		"client.id":         defaultClientID,
+		"security.protocol": "SASL_SSL",
+		"sasl.mechanism":    "SCRAM-SHA-512",
+		"sasl.username":     "user",
+		"sasl.password":     "secret",
+		"ssl.ca.location":   "/etc/ssl/ca.pem",
+	}
+	for k, v := range expected {
+		if cfg[k] != v {
+			t.Errorf("expected %s to be %v, got %v", k, v, cfg[k])
+		}
+	}
+}
+
+func TestSecurityConfig_Protocol(t *testing.T) {
+	cases := []struct {
+		security SecurityConfig
+		expected string
+	}{
+		{SecurityConfig{}, ""},
+		{SecurityConfig{CAFile: "ca.pem"}, "SSL"},
+		{SecurityConfig{SASLMechanism: "PLAIN"}, "SASL_SSL"},
+		{SecurityConfig{SASLMechanism: "PLAIN", Protocol: "SASL_PLAINTEXT"}, "SASL_PLAINTEXT"},
+	}
+	for _, c := range cases {
+		if got := c.security.protocol(); got != c.expected {
+			t.Errorf("expected protocol %q for %+v, got %q", c.expected, c.security, got)
+		}
+	}
+}
+
+func TestConsumerConfig_Overrides(t *testing.T) {
+	cfg := newConfig(
+		WithAutoOffsetReset("latest"),
+		WithConfigMap(ckafka.ConfigMap{"socket.timeout.ms": 1000}),
+		WithConsumerConfigMap(ckafka.ConfigMap{
+			"fetch.min.bytes":    1024,
+			"group.id":           "ignored",
+			"enable.auto.commit": true,
+		}),
+		WithProducerConfigMap(ckafka.ConfigMap{"linger.ms": 5}),
+	)
+
+	consumer := *cfg.consumerConfig("orders-group", false)
+	if consumer["auto.offset.reset"] != "latest" {
+		t.Errorf("expected auto.offset.reset 'latest', got %v", consumer["auto.offset.reset"])
+	}
+	if consumer["socket.timeout.ms"] != 1000 || consumer["fetch.min.bytes"] != 1024 {
+		t.Errorf("expected pass-through properties, got %v", consumer)
+	}
+	if consumer["group.id"] != "orders-group" || consumer["enable.auto.commit"] != false {
+		t.Errorf("expected pool-owned properties to win, got %v", consumer)
+	}
+	if _, ok := consumer["linger.ms"]; ok {
+		t.Error("expected producer properties not to reach consumers")
+	}
+
+	producer := *cfg.producerConfig()
+	if producer["linger.ms"] != 5 || producer["socket.timeout.ms"] != 1000 {
+		t.Errorf("expected producer pass-through properties, got %v", producer)
+	}
+	if _, ok := producer["fetch.min.bytes"]; ok {
+		t.Error("expected consumer properties not to reach producers")
+	}
+}
@@ -1,26 +1,36 @@
 package kafka
 
 import (
-	"strings"
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 
 	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/liberty-group-tech/wello-go-common/logging"
 )
 
+// ErrPoolClosed is returned by Borrow once the pool or its manager is closed.
+var ErrPoolClosed = errors.New("kafka consumer pool is closed")
+
 type ConsumerPool struct {
-	mu      sync.Mutex
-	cfg     *Config
-	groupID string
-	topic   string
-	pool    chan *ckafka.Consumer
-	once    sync.Once
-}
+	mu         sync.Mutex
+	cfg        *Config
+	groupID    string
+	topic      string
+	autoCommit bool
+	pool       chan Reader
+	once       sync.Once
 
-var (
-	poolMap = make(map[string]*ConsumerPool)
-	mapMu   sync.Mutex
-)
+	// consumers holds every open consumer, idle or borrowed.
+	consumers map[Reader]struct{}
+	// revokeHooks are called with the partitions revoked from a borrowed
+	// consumer, see onRevoke.
+	revokeHooks map[Reader]func([]ckafka.TopicPartition)
+	closed      bool
+	// drained is closed once the pool is closed and its last consumer too.
+	drained chan struct{}
+}
 
 func poolKey(groupID string, topic string) string {
 	return groupID + "::" + topic
@@ -29,6 +39,11 @@ func poolKey(groupID string, topic string) string {
 type ConsumerManager struct {
 	cfg    *Config
 	logger logging.Logger
+
+	mu     sync.Mutex
+	pools  map[string]*ConsumerPool
+	admin  *Admin
+	closed bool
 }
 
 func NewConsumerManager(opts ...Option) *ConsumerManager {
@@ -38,74 +53,290 @@ func NewConsumerManager(opts ...Option) *ConsumerManager {
 	return &ConsumerManager{
 		cfg:    cfg,
 		logger: cfg.Logger,
+		pools:  make(map[string]*ConsumerPool),
 	}
 }
 
 func (cm *ConsumerManager) GetPool(topic string) *ConsumerPool {
+	return cm.getPool(topic, true)
+}
+
+// GetManualCommitPool returns a pool whose consumers never auto-commit, for
+// callers that commit offsets themselves, e.g. in a transaction.
+func (cm *ConsumerManager) GetManualCommitPool(topic string) *ConsumerPool {
+	return cm.getPool(topic, false)
+}
+
+// getPool returns the pool for topic. Pools with autoCommit disabled hand out
+// consumers whose offsets are only committed explicitly, and are kept apart
+// from the auto-committing pools returned by GetPool.
+func (cm *ConsumerManager) getPool(topic string, autoCommit bool) *ConsumerPool {
 	key := poolKey(cm.cfg.GroupID, topic)
+	if !autoCommit {
+		key += "::manual"
+	}
 
-	mapMu.Lock()
-	defer mapMu.Unlock()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
-	if pool, ok := poolMap[key]; ok {
+	if pool, ok := cm.pools[key]; ok {
 		return pool
 	}
 
 	cp := &ConsumerPool{
-		cfg:     cm.cfg,
-		groupID: cm.cfg.GroupID,
-		topic:   topic,
-		pool:    make(chan *ckafka.Consumer, 1000),
+		cfg:         cm.cfg,
+		groupID:     cm.cfg.GroupID,
+		topic:       cm.cfg.TopicName(topic),
+		autoCommit:  autoCommit,
+		pool:        make(chan Reader, 1000),
+		consumers:   make(map[Reader]struct{}),
+		revokeHooks: make(map[Reader]func([]ckafka.TopicPartition)),
+		closed:      cm.closed,
+		drained:     make(chan struct{}),
 	}
-	poolMap[key] = cp
+	if cp.closed {
+		close(cp.drained)
+	}
+	cm.pools[key] = cp
 	return cp
 }
 
-func (cp *ConsumerPool) Borrow() (*ckafka.Consumer, func()) {
-	var consumer *ckafka.Consumer
-	var returnFunc func()
+// Close closes every pool of the manager, see ConsumerPool.Close. Pools
+// requested after Close are closed from the start.
+func (cm *ConsumerManager) Close(ctx context.Context) error {
+	cm.mu.Lock()
+	cm.closed = true
+	pools := make([]*ConsumerPool, 0, len(cm.pools))
+	for _, pool := range cm.pools {
+		pools = append(pools, pool)
+	}
+	admin := cm.admin
+	cm.admin = nil
+	cm.mu.Unlock()
+
+	if admin != nil {
+		admin.Close()
+	}
+
+	var errs []error
+	for _, pool := range pools {
+		if err := pool.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ErrNotKafkaConsumer is returned by Borrow when the pool creates its
+// consumers with a ReaderFactory, see BorrowReader.
+var ErrNotKafkaConsumer = errors.New("kafka consumer pool does not hand out *ckafka.Consumer")
+
+// Borrow takes an idle consumer from the pool or creates a new one. The
+// returned func gives the consumer back to the pool. Pools configured with
+// WithReaderFactory fail with ErrNotKafkaConsumer; use BorrowReader there.
+func (cp *ConsumerPool) Borrow() (*ckafka.Consumer, func(), error) {
+	reader, release, err := cp.BorrowReader()
+	if err != nil {
+		return nil, nil, err
+	}
+	consumer, ok := reader.(*ckafka.Consumer)
+	if !ok {
+		release()
+		return nil, nil, fmt.Errorf("%w: got %T", ErrNotKafkaConsumer, reader)
+	}
+	return consumer, release, nil
+}
+
+// BorrowReader is Borrow for code that only needs a Reader, and works with
+// the consumers of a ReaderFactory too.
+func (cp *ConsumerPool) BorrowReader() (Reader, func(), error) {
+	cp.mu.Lock()
+	closed := cp.closed
+	cp.mu.Unlock()
+	if closed {
+		return nil, nil, ErrPoolClosed
+	}
+
+	var consumer Reader
 	select {
 	case consumer = <-cp.pool:
-		returnFunc = func() {
-			cp.Return(consumer)
-		}
 	default:
-		consumer = cp.createAndPut()
-		returnFunc = func() {
-			cp.Return(consumer)
+		var err error
+		consumer, err = cp.newConsumer()
+		if err != nil {
+			return nil, nil, err
 		}
 	}
-	return consumer, returnFunc
+	return consumer, func() {
+		cp.put(consumer)
+	}, nil
 }
 
+// Return gives a borrowed consumer back to the pool. Consumers returned to a
+// closed pool are closed.
 func (cp *ConsumerPool) Return(consumer *ckafka.Consumer) {
-	cp.pool <- consumer
+	cp.put(consumer)
+}
+
+func (cp *ConsumerPool) put(consumer Reader) {
+	// The consumer is pushed while holding mu, so it cannot land in the pool
+	// after Close has drained it.
+	cp.mu.Lock()
+	if !cp.closed {
+		select {
+		case cp.pool <- consumer:
+			cp.mu.Unlock()
+			return
+		default:
+		}
+	}
+	cp.mu.Unlock()
+	cp.closeConsumer(consumer)
+}
+
+// Discard closes a borrowed consumer instead of giving it back to the pool,
+// for consumers whose position can no longer be trusted. Messages they did
+// not commit are consumed again once their partitions are reassigned.
+func (cp *ConsumerPool) Discard(consumer *ckafka.Consumer) {
+	cp.discard(consumer)
+}
+
+func (cp *ConsumerPool) discard(consumer Reader) {
+	cp.closeConsumer(consumer)
 }
 
-func (cp *ConsumerPool) createAndPut() *ckafka.Consumer {
+// Close closes the idle consumers of the pool right away, so they commit
+// their offsets and leave the consumer group, and closes borrowed consumers
+// as they are returned. It waits for all of them until ctx is done.
+func (cp *ConsumerPool) Close(ctx context.Context) error {
+	cp.mu.Lock()
+	if !cp.closed {
+		cp.closed = true
+		if len(cp.consumers) == 0 {
+			close(cp.drained)
+		}
+	}
+	cp.mu.Unlock()
+
+	for {
+		select {
+		case consumer := <-cp.pool:
+			cp.closeConsumer(consumer)
+		default:
+			select {
+			case <-cp.drained:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (cp *ConsumerPool) closeConsumer(consumer Reader) {
+	if err := consumer.Close(); err != nil {
+		cp.cfg.Logger.Errorf("Failed to close consumer of topic %s: %v", cp.topic, err)
+	}
+
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
+	delete(cp.consumers, consumer)
+	if cp.closed && len(cp.consumers) == 0 {
+		select {
+		case <-cp.drained:
+		default:
+			close(cp.drained)
+		}
+	}
+}
 
-	consumer, err := ckafka.NewConsumer(&ckafka.ConfigMap{
-		"bootstrap.servers": strings.Join(cp.cfg.Brokers, ","),
-		"group.id":          cp.groupID,
-		"client.id":         cp.cfg.ClientID,
-		"auto.offset.reset": "earliest",
-	})
+// newConsumer creates a consumer subscribed to the pool's topic, with the
+// configured ReaderFactory if any.
+func (cp *ConsumerPool) newConsumer() (Reader, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.closed {
+		return nil, ErrPoolClosed
+	}
+
+	if cp.cfg.ReaderFactory != nil {
+		reader, err := cp.cfg.ReaderFactory(cp.groupID, cp.topic, cp.autoCommit)
+		if err != nil {
+			return nil, err
+		}
+		cp.consumers[reader] = struct{}{}
+		return reader, nil
+	}
+
+	consumer, err := ckafka.NewConsumer(cp.cfg.consumerConfig(cp.groupID, cp.autoCommit))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	cp.once.Do(func() {
-		if err := ensureTopics(cp.cfg, nil, consumer); err != nil {
+		if err := ensureTopics(cp.cfg, cp.cfg.Topics, nil, consumer); err != nil {
 			cp.cfg.Logger.Errorf("Failed to ensure topics: %v", err)
 		}
 	})
 
-	if err := consumer.Subscribe(cp.topic, nil); err != nil {
-		panic(err)
+	if err := consumer.Subscribe(cp.topic, cp.rebalance); err != nil {
+		_ = consumer.Close()
+		return nil, err
+	}
+
+	cp.consumers[consumer] = struct{}{}
+	return consumer, nil
+}
+
+// onRevoke calls hook with the partitions revoked from consumer until the
+// returned func is called. Like every rebalance callback, hook runs on the
+// goroutine polling consumer.
+func (cp *ConsumerPool) onRevoke(consumer Reader, hook func([]ckafka.TopicPartition)) func() {
+	cp.mu.Lock()
+	cp.revokeHooks[consumer] = hook
+	cp.mu.Unlock()
+	return func() {
+		cp.mu.Lock()
+		delete(cp.revokeHooks, consumer)
+		cp.mu.Unlock()
 	}
+}
 
-	cp.pool <- consumer
-	return <-cp.pool
+// rebalance logs partition assignments and revocations, passes revocations
+// to the consumer's revoke hook and passes both on to the callback
+// configured with WithRebalanceCallback.
+func (cp *ConsumerPool) rebalance(consumer *ckafka.Consumer, event ckafka.Event) error {
+	switch e := event.(type) {
+	case ckafka.AssignedPartitions:
+		cp.cfg.Logger.Infof("Consumer %s assigned partitions %v", consumer, e.Partitions)
+	case ckafka.RevokedPartitions:
+		cp.cfg.Logger.Infof("Consumer %s revoked partitions %v", consumer, e.Partitions)
+		cp.mu.Lock()
+		hook := cp.revokeHooks[consumer]
+		cp.mu.Unlock()
+		if hook != nil {
+			hook(e.Partitions)
+		}
+	}
+
+	if cp.cfg.RebalanceCallback != nil {
+		return cp.cfg.RebalanceCallback(consumer, event)
+	}
+	return nil
+}
+
+// seekPartitions seeks consumer to offsets, failing when any partition could
+// not be sought.
+func seekPartitions(consumer Reader, offsets []ckafka.TopicPartition) error {
+	partitions, err := consumer.SeekPartitions(offsets)
+	if err != nil {
+		return err
+	}
+	for _, p := range partitions {
+		if p.Error != nil {
+			return fmt.Errorf("failed to seek %s: %w", p, p.Error)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func newTestManager(t *testing.T, opts ...Option) *ConsumerManager {
+	t.Helper()
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+
+	return NewConsumerManager(append([]Option{
+		WithBrokers([]string{cluster.BootstrapServers()}),
+		WithGroupID("test-group"),
+	}, opts...)...)
+}
+
+func TestConsumerManager_PoolsArePerManager(t *testing.T) {
+	first := NewConsumerManager(WithGroupID("g"))
+	second := NewConsumerManager(WithGroupID("g"))
+
+	if first.GetPool("orders") != first.GetPool("orders") {
+		t.Error("expected the same pool for the same topic")
+	}
+	if first.GetPool("orders") == second.GetPool("orders") {
+		t.Error("expected managers not to share pools")
+	}
+	if first.GetPool("orders") == first.GetManualCommitPool("orders") {
+		t.Error("expected manual commit pool to be separate")
+	}
+}
+
+func TestConsumerManager_Close(t *testing.T) {
+	cm := newTestManager(t)
+	pool := cm.GetPool("orders")
+
+	consumer, release, err := pool.Borrow()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cm.Close(ctx); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+	if !consumer.IsClosed() {
+		t.Error("expected idle consumer to be closed")
+	}
+
+	if _, _, err := pool.Borrow(); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed, got %v", err)
+	}
+	if _, _, err := cm.GetPool("payments").Borrow(); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed for pool created after close, got %v", err)
+	}
+}
+
+func TestConsumerPool_CloseWaitsForBorrowed(t *testing.T) {
+	cm := newTestManager(t)
+	pool := cm.GetPool("orders")
+
+	consumer, release, err := pool.Borrow()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded while consumer is borrowed, got %v", err)
+	}
+
+	release()
+	if !consumer.IsClosed() {
+		t.Error("expected consumer returned to a closed pool to be closed")
+	}
+	if err := pool.Close(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConsumerPool_CloseWhileReturning(t *testing.T) {
+	cm := newTestManager(t)
+	pool := cm.GetPool("orders")
+
+	releases := make([]func(), 5)
+	for i := range releases {
+		_, release, err := pool.Borrow()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		releases[i] = release
+	}
+	for _, release := range releases {
+		go release()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := pool.Close(ctx); err != nil {
+		t.Fatalf("expected every returned consumer to be closed, got %v", err)
+	}
+}
+
+func TestConsumerPool_BorrowReader(t *testing.T) {
+	cm, reader := newFatalManager()
+	pool := cm.GetPool("orders")
+
+	if _, _, err := pool.Borrow(); !errors.Is(err, ErrNotKafkaConsumer) {
+		t.Fatalf("expected ErrNotKafkaConsumer, got %v", err)
+	}
+	borrowed, release, err := pool.BorrowReader()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if borrowed != reader {
+		t.Errorf("expected the reader of the factory, got %T", borrowed)
+	}
+	release()
+	if reader.closed {
+		t.Error("expected the reader to be kept in the pool")
+	}
+}
@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/helper"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultDedupePrefix     = "kafka-dedupe"
+	defaultDedupeTTL        = 24 * time.Hour
+	defaultDedupeProcessing = 5 * time.Minute
+
+	dedupeProcessing = "processing"
+	dedupeDone       = "done"
+)
+
+// ErrDuplicateInProgress is returned by a deduplicated handler when the same
+// message is being handled elsewhere. The message is retried like any other
+// failure and skipped once the other handler has completed it.
+var ErrDuplicateInProgress = errors.New("kafka message is already being processed")
+
+type dedupeOptions struct {
+	key        func(msg *ckafka.Message) string
+	prefix     string
+	ttl        time.Duration
+	processing time.Duration
+	logger     logging.Logger
+}
+
+type DedupeOption func(*dedupeOptions)
+
+// WithDedupeKey sets the func that identifies a message, the message-id
+// header by default. Messages for which it returns "" are not deduplicated.
+func WithDedupeKey(key func(msg *ckafka.Message) string) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.key = key
+	}
+}
+
+// WithDedupePrefix sets the prefix of the redis keys, "kafka-dedupe" by
+// default. Use a distinct prefix per consumer group when several groups
+// handle the same messages.
+func WithDedupePrefix(prefix string) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithDedupeTTL sets how long a processed message is remembered, 24h by
+// default.
+func WithDedupeTTL(ttl time.Duration) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithDedupeProcessingTTL sets how long a message is claimed while it is
+// handled, 5m by default. A claim left behind by a crashed consumer expires
+// after this TTL.
+func WithDedupeProcessingTTL(ttl time.Duration) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.processing = ttl
+	}
+}
+
+// WithDedupeLogger sets the logger reporting messages that were handled but
+// could not be marked as completed. Nothing is logged by default.
+func WithDedupeLogger(logger logging.Logger) DedupeOption {
+	return func(o *dedupeOptions) {
+		o.logger = logger
+	}
+}
+
+func messageIDKey(msg *ckafka.Message) string {
+	id, _ := HeaderValue(msg.Headers, HeaderMessageID)
+	return id
+}
+
+// Deduplicate is a Middleware that lets every message be handled
+// successfully at most once within the dedupe TTL.
+//
+// Before calling next the message is claimed in redis with SETNX and a token
+// unique to the claim. Messages already completed are skipped, and messages
+// claimed by another consumer fail with ErrDuplicateInProgress. The message
+// is marked as completed only when next succeeds; when it fails the claim is
+// dropped so the message can be retried. Both happen only while the claim
+// still holds the token, so a claim that expired and was taken by another
+// consumer is left alone.
+func Deduplicate(client *redis.Client, opts ...DedupeOption) Middleware {
+	options := &dedupeOptions{
+		key:        messageIDKey,
+		prefix:     defaultDedupePrefix,
+		ttl:        defaultDedupeTTL,
+		processing: defaultDedupeProcessing,
+		logger:     &logging.NoOpLogger{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) error {
+			id := options.key(msg)
+			if id == "" {
+				return next(ctx, msg)
+			}
+			key := options.prefix + ":" + id
+			token := helper.GenerateID(dedupeProcessing)
+
+			claimed, err := client.SetNX(ctx, key, token, options.processing)
+			if err != nil {
+				return fmt.Errorf("failed to claim message %s: %w", id, err)
+			}
+			if !claimed {
+				state, err := client.Get(ctx, key)
+				switch {
+				case errors.Is(err, goredis.Nil):
+					// The claim expired in between; let the message be retried.
+					return ErrDuplicateInProgress
+				case err != nil:
+					return fmt.Errorf("failed to get state of message %s: %w", id, err)
+				case state == dedupeDone:
+					return nil
+				default:
+					return ErrDuplicateInProgress
+				}
+			}
+
+			if err := next(ctx, msg); err != nil {
+				if delErr := releaseClaim(context.WithoutCancel(ctx), client, key, token); delErr != nil {
+					return errors.Join(err, fmt.Errorf("failed to release message %s: %w", id, delErr))
+				}
+				return err
+			}
+
+			// The message was handled: failing here would only get it handled
+			// again once the claim expires, so a failed mark is logged but not
+			// reported.
+			if err := completeClaim(context.WithoutCancel(ctx), client, key, token, options.ttl); err != nil {
+				options.logger.Errorf("Failed to mark message %s as processed, it is handled again once its claim expires: %v", id, err)
+			}
+			return nil
+		}
+	}
+}
+
+// releaseClaim deletes key if it still holds token.
+func releaseClaim(ctx context.Context, client *redis.Client, key, token string) error {
+	cluster, err := client.Cluster()
+	if err != nil {
+		return err
+	}
+
+	script := `if redis.call("GET", KEYS[1]) == ARGV[1] then
+        return redis.call("DEL", KEYS[1])
+    else
+        return 0
+    end`
+
+	return cluster.Eval(ctx, script, []string{key}, token).Err()
+}
+
+// completeClaim marks key as done for ttl if it still holds token.
+func completeClaim(ctx context.Context, client *redis.Client, key, token string, ttl time.Duration) error {
+	cluster, err := client.Cluster()
+	if err != nil {
+		return err
+	}
+
+	script := `if redis.call("GET", KEYS[1]) == ARGV[1] then
+        return redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+    else
+        return 0
+    end`
+
+	return cluster.Eval(ctx, script, []string{key}, token, dedupeDone, int(ttl/time.Millisecond)).Err()
+}
@@ -0,0 +1,148 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func TestDeduplicate(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(redis.WithClusterOptions(&goredis.ClusterOptions{Addrs: []string{server.Addr()}}))
+	if err != nil {
+		t.Fatalf("failed to create redis client: %v", err)
+	}
+
+	calls := 0
+	fail := true
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		calls++
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}, Deduplicate(client, WithDedupeTTL(time.Hour)))
+
+	ctx := context.Background()
+	msg := &ckafka.Message{
+		Headers: []ckafka.Header{{Key: HeaderMessageID, Value: []byte("m-1")}},
+	}
+
+	if err := handler(ctx, msg); err == nil {
+		t.Fatal("expected handler error")
+	}
+	if server.Exists("kafka-dedupe:m-1") {
+		t.Error("expected claim to be dropped after a failure")
+	}
+
+	fail = false
+	if err := handler(ctx, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := handler(ctx, msg); err != nil {
+		t.Fatalf("unexpected error on duplicate: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected duplicate to be skipped, got %d calls", calls)
+	}
+	if ttl := server.TTL("kafka-dedupe:m-1"); ttl != time.Hour {
+		t.Errorf("expected processed marker to expire after 1h, got %v", ttl)
+	}
+
+	server.Set("kafka-dedupe:m-2", dedupeProcessing)
+	inProgress := &ckafka.Message{
+		Headers: []ckafka.Header{{Key: HeaderMessageID, Value: []byte("m-2")}},
+	}
+	if err := handler(ctx, inProgress); !errors.Is(err, ErrDuplicateInProgress) {
+		t.Errorf("expected ErrDuplicateInProgress, got %v", err)
+	}
+
+	if err := handler(ctx, &ckafka.Message{}); err != nil {
+		t.Errorf("expected messages without ID to pass through, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDeduplicate_KeepsClaimTakenOver(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(redis.WithClusterOptions(&goredis.ClusterOptions{Addrs: []string{server.Addr()}}))
+	if err != nil {
+		t.Fatalf("failed to create redis client: %v", err)
+	}
+
+	// The claim expires while the message is handled and another consumer
+	// claims it.
+	fail := false
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		server.Set("kafka-dedupe:m-1", "processing_other")
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}, Deduplicate(client))
+
+	msg := &ckafka.Message{
+		Headers: []ckafka.Header{{Key: HeaderMessageID, Value: []byte("m-1")}},
+	}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, _ := server.Get("kafka-dedupe:m-1"); v != "processing_other" {
+		t.Errorf("expected the other claim to be kept after a success, got %q", v)
+	}
+
+	server.Del("kafka-dedupe:m-1")
+	fail = true
+	if err := handler(context.Background(), msg); err == nil {
+		t.Fatal("expected handler error")
+	}
+	if v, _ := server.Get("kafka-dedupe:m-1"); v != "processing_other" {
+		t.Errorf("expected the other claim to be kept after a failure, got %q", v)
+	}
+}
+
+// errorLogger records the errors it is given.
+type errorLogger struct {
+	logging.NoOpLogger
+	errors []string
+}
+
+func (l *errorLogger) Errorf(format string, args ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDeduplicate_LogsFailedCompletion(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(redis.WithClusterOptions(&goredis.ClusterOptions{Addrs: []string{server.Addr()}}))
+	if err != nil {
+		t.Fatalf("failed to create redis client: %v", err)
+	}
+
+	logger := &errorLogger{}
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		// Redis goes away while the message is handled.
+		server.Close()
+		return nil
+	}, Deduplicate(client, WithDedupeLogger(logger)))
+
+	msg := &ckafka.Message{
+		Headers: []ckafka.Header{{Key: HeaderMessageID, Value: []byte("m-1")}},
+	}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("expected the handled message to succeed, got %v", err)
+	}
+	if len(logger.errors) != 1 || !strings.Contains(logger.errors[0], "m-1") {
+		t.Errorf("expected the failed completion to be logged, got %v", logger.errors)
+	}
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Metadata is the envelope stamped on every message sent by Publish.
+type Metadata struct {
+	MessageID  string
+	EventType  string
+	Producer   string
+	Env        string
+	ProducedAt time.Time
+}
+
+// MetadataFromHeaders reads the envelope headers back from a message. Fields
+// whose header is missing or malformed are left empty.
+func MetadataFromHeaders(headers []ckafka.Header) Metadata {
+	var meta Metadata
+	meta.MessageID, _ = HeaderValue(headers, HeaderMessageID)
+	meta.EventType, _ = HeaderValue(headers, HeaderEventType)
+	meta.Producer, _ = HeaderValue(headers, HeaderProducer)
+	meta.Env, _ = HeaderValue(headers, HeaderEnv)
+	if producedAt, ok := HeaderValue(headers, HeaderProducedAt); ok {
+		meta.ProducedAt, _ = time.Parse(time.RFC3339Nano, producedAt)
+	}
+	return meta
+}
+
+// Metadata returns the envelope metadata of the message.
+func (m *Message[T]) Metadata() Metadata {
+	return MetadataFromHeaders(m.Raw.Headers)
+}
+
+// headers returns the envelope headers. The env header is left to the
+// producer, which adds it to every message.
+func (m Metadata) headers() []ckafka.Header {
+	return []ckafka.Header{
+		{Key: HeaderMessageID, Value: []byte(m.MessageID)},
+		{Key: HeaderEventType, Value: []byte(m.EventType)},
+		{Key: HeaderProducer, Value: []byte(m.Producer)},
+		{Key: HeaderProducedAt, Value: []byte(m.ProducedAt.Format(time.RFC3339Nano))},
+	}
+}
+
+// Publish encodes value with the producer's Serializer, plain JSON by
+// default, stamps the envelope headers and sends it to topic, waiting for the
+// delivery report. It returns the envelope that was sent.
+func Publish[T any](ctx context.Context, p *Producer, topic string, eventType string, value T, opts ...MessageOption) (Metadata, error) {
+	// Serializers deriving a schema subject from the topic get the topic
+	// actually written, so namespaces do not share subjects.
+	serializer := p.cfg.Serializer
+	data, err := serializer.Serialize(p.cfg.TopicName(topic), value)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to serialize %s event: %w", eventType, err)
+	}
+
+	meta := Metadata{
+		MessageID:  p.cfg.IDGenerator.GenerateID(),
+		EventType:  eventType,
+		Producer:   p.cfg.ClientID,
+		Env:        p.cfg.Environment,
+		ProducedAt: time.Now(),
+	}
+
+	msgOpts := make([]MessageOption, 0, len(opts)+2)
+	msgOpts = append(msgOpts, WithTimestamp(meta.ProducedAt))
+	msgOpts = append(msgOpts, opts...)
+	msgOpts = append(msgOpts, WithMessageHeaders(meta.headers()...))
+	msgOpts = append(msgOpts, withHeader(HeaderContentType, []byte(serializer.ContentType())))
+
+	if _, err := p.SendSync(ctx, topic, data, msgOpts...); err != nil {
+		return Metadata{}, err
+	}
+	return meta, nil
+}
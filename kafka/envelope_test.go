@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestMetadataHeadersRoundTrip(t *testing.T) {
+	p := &Producer{cfg: newConfig(WithEnvironment("staging"))}
+	meta := Metadata{
+		MessageID:  "msg_1",
+		EventType:  "order.created",
+		Producer:   "checkout",
+		Env:        "staging",
+		ProducedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+
+	msg := p.buildMessage("orders", nil, WithMessageHeaders(meta.headers()...))
+	got := (&Message[[]byte]{Raw: msg}).Metadata()
+
+	if got.MessageID != meta.MessageID || got.EventType != meta.EventType ||
+		got.Producer != meta.Producer || got.Env != meta.Env {
+		t.Errorf("expected %+v, got %+v", meta, got)
+	}
+	if !got.ProducedAt.Equal(meta.ProducedAt) {
+		t.Errorf("expected ProducedAt %v, got %v", meta.ProducedAt, got.ProducedAt)
+	}
+}
+
+func TestMetadataFromHeaders_Empty(t *testing.T) {
+	got := MetadataFromHeaders(nil)
+	if got != (Metadata{}) {
+		t.Errorf("expected empty metadata, got %+v", got)
+	}
+}
+
+// stubSerializer encodes values as JSON but labels them with contentType.
+type stubSerializer struct {
+	contentType string
+}
+
+func (s stubSerializer) ContentType() string {
+	return s.contentType
+}
+
+func (s stubSerializer) Serialize(_ string, value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func TestPublish_SingleContentType(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("orders", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	producer, err := NewProducer(
+		WithBrokers([]string{cluster.BootstrapServers()}),
+		WithSerializer(stubSerializer{contentType: ContentTypeAvro}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err = Publish(ctx, producer, "orders", "order.created", testEvent{ID: "o-1"},
+		WithMessageHeaders(ckafka.Header{Key: HeaderContentType, Value: []byte(ContentTypeJSON)}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	consumer, err := ckafka.NewConsumer(&ckafka.ConfigMap{
+		"bootstrap.servers": cluster.BootstrapServers(),
+		"group.id":          "envelope-test",
+		"auto.offset.reset": "earliest",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer consumer.Close()
+	if err := consumer.Subscribe("orders", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msg, err := consumer.ReadMessage(10 * time.Second)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+
+	var contentTypes []string
+	for _, h := range msg.Headers {
+		if h.Key == HeaderContentType {
+			contentTypes = append(contentTypes, string(h.Value))
+		}
+	}
+	if len(contentTypes) != 1 || contentTypes[0] != ContentTypeAvro {
+		t.Errorf("expected a single %s Content-Type header, got %v", ContentTypeAvro, contentTypes)
+	}
+}
+
+// recordingSerializer encodes values as JSON and records the topics it
+// serialized for.
+type recordingSerializer struct {
+	mu     sync.Mutex
+	topics []string
+}
+
+func (s *recordingSerializer) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (s *recordingSerializer) Serialize(topic string, value any) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topics = append(s.topics, topic)
+	return json.Marshal(value)
+}
+
+func TestSerialize_UsesNamespacedTopic(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	for _, topic := range []string{"staging.orders", "staging.quotes", "staging.quotes.replies"} {
+		if err := cluster.CreateTopic(topic, 1, 1); err != nil {
+			t.Fatalf("failed to create topic: %v", err)
+		}
+	}
+
+	serializer := &recordingSerializer{}
+	producer, err := NewProducer(
+		WithBrokers([]string{cluster.BootstrapServers()}),
+		WithTopicNaming("staging.", ""),
+		WithSerializer(serializer),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if _, err := Publish(ctx, producer, "orders", "order.created", testEvent{ID: "o-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requester, err := NewRequester(producer, "quotes.replies")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer requester.Close()
+	callCtx, stop := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer stop()
+	// Nobody answers: only the serialized request matters.
+	_, _ = Call[testEvent, testEvent](callCtx, requester, "quotes", testEvent{ID: "q-1"})
+
+	want := []string{"staging.orders", "staging.quotes"}
+	if len(serializer.topics) != len(want) || serializer.topics[0] != want[0] || serializer.topics[1] != want[1] {
+		t.Errorf("expected values serialized for %v, got %v", want, serializer.topics)
+	}
+}
@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"mime"
+	"strings"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/samber/lo"
+)
+
+const (
+	HeaderContentType = "Content-Type"
+	HeaderEnv         = "env"
+)
+
+// Envelope headers stamped by Publish.
+const (
+	HeaderMessageID  = "message-id"
+	HeaderEventType  = "event-type"
+	HeaderProducer   = "producer"
+	HeaderProducedAt = "produced-at"
+)
+
+// Headers recorded on messages forwarded to retry and dead-letter topics.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderRetryAttempt      = "x-retry-attempt"
+	HeaderRetryAt           = "x-retry-at"
+	HeaderLastError         = "x-last-error"
+)
+
+// HeaderValue returns the value of the last header with the given key.
+func HeaderValue(headers []ckafka.Header, key string) (string, bool) {
+	for i := len(headers) - 1; i >= 0; i-- {
+		if headers[i].Key == key {
+			return string(headers[i].Value), true
+		}
+	}
+	return "", false
+}
+
+// withoutHeaders returns a copy of headers with the given keys removed.
+func withoutHeaders(headers []ckafka.Header, keys ...string) []ckafka.Header {
+	out := make([]ckafka.Header, 0, len(headers))
+	for _, h := range headers {
+		if !lo.Contains(keys, h.Key) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// isJSONContentType reports whether contentType denotes a JSON payload.
+// Messages without a Content-Type header are treated as JSON.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
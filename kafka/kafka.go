@@ -2,9 +2,9 @@ package kafka
 
 import (
 	"context"
-	"strings"
 
 	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/helper"
 	"github.com/liberty-group-tech/wello-go-common/logging"
 	"github.com/samber/lo"
 )
@@ -14,30 +14,67 @@ const (
 	defaultClientID          = "default-client"
 	defaultPartitions        = 6
 	defaultReplicationFactor = 3
+	defaultAutoOffsetReset   = "earliest"
 )
 
 type Config struct {
-	Brokers           []string
-	ClientID          string
-	GroupID           string
-	Environment       string
+	Brokers     []string
+	ClientID    string
+	AppName     string
+	GroupID     string
+	Environment string
+	// TopicPrefix and TopicSuffix wrap every topic name, see WithTopicNaming.
+	TopicPrefix       string
+	TopicSuffix       string
 	Topics            []string
 	Partitions        int
 	ReplicationFactor int
 	Logger            logging.Logger
 	Headers           []ckafka.Header
+	IDGenerator       *helper.IDGenerator
+	Idempotence       bool
+	TransactionalID   string
+	RebalanceCallback ckafka.RebalanceCb
+	// ReaderFactory, when set, creates the consumers of consumer pools, see
+	// WithReaderFactory.
+	ReaderFactory     ReaderFactory
+	AutoOffsetReset   string
+	Security          SecurityConfig
+	ConfigMap         ckafka.ConfigMap
+	ProducerConfigMap ckafka.ConfigMap
+	ConsumerConfigMap ckafka.ConfigMap
+	Serializer        Serializer
+	// Deserializers maps Content-Type media types to their Deserializer.
+	Deserializers          map[string]Deserializer
+	WireFormatDeserializer Deserializer
 }
 
 type configOptions struct {
 	brokers           []string
 	clientID          string
+	appName           string
 	groupID           string
 	environment       string
+	topicPrefix       string
+	topicSuffix       string
 	topics            []string
 	partitions        int
 	replicationFactor int
 	logger            logging.Logger
 	headers           []ckafka.Header
+	idGenerator       *helper.IDGenerator
+	idempotence       bool
+	transactionalID   string
+	rebalanceCallback ckafka.RebalanceCb
+	readerFactory     ReaderFactory
+	autoOffsetReset   string
+	security          SecurityConfig
+	configMap         ckafka.ConfigMap
+	producerConfigMap ckafka.ConfigMap
+	consumerConfigMap ckafka.ConfigMap
+	serializer        Serializer
+	deserializers     map[string]Deserializer
+	wireFormat        Deserializer
 }
 
 type Option func(*configOptions)
@@ -90,16 +127,139 @@ func WithLogger(logger logging.Logger) Option {
 	}
 }
 
+// WithIDGenerator sets the generator of message IDs stamped by Publish.
+func WithIDGenerator(generator *helper.IDGenerator) Option {
+	return func(o *configOptions) {
+		o.idGenerator = generator
+	}
+}
+
+// WithIdempotence enables the idempotent producer, which writes every
+// message exactly once and in order per partition despite retries.
+func WithIdempotence(enabled bool) Option {
+	return func(o *configOptions) {
+		o.idempotence = enabled
+	}
+}
+
+// WithTransactionalID makes producers transactional, see
+// Producer.BeginTransaction. Transactions imply idempotence.
+func WithTransactionalID(transactionalID string) Option {
+	return func(o *configOptions) {
+		o.transactionalID = transactionalID
+	}
+}
+
+// WithRebalanceCallback observes partition assignments and revocations of
+// pooled consumers. The event is a ckafka.AssignedPartitions or a
+// ckafka.RevokedPartitions; partitions are (un)assigned automatically unless
+// the callback does so itself.
+func WithRebalanceCallback(callback ckafka.RebalanceCb) Option {
+	return func(o *configOptions) {
+		o.rebalanceCallback = callback
+	}
+}
+
+// WithAutoOffsetReset sets where consumers start when their group has no
+// committed offset: "earliest" (default), "latest" or "error".
+func WithAutoOffsetReset(reset string) Option {
+	return func(o *configOptions) {
+		o.autoOffsetReset = reset
+	}
+}
+
+// WithSecurityProtocol sets security.protocol: PLAINTEXT, SSL,
+// SASL_PLAINTEXT or SASL_SSL. See SecurityConfig for the default.
+func WithSecurityProtocol(protocol string) Option {
+	return func(o *configOptions) {
+		o.security.Protocol = protocol
+	}
+}
+
+// WithSASL authenticates with the given SASL mechanism, e.g. PLAIN,
+// SCRAM-SHA-256 or SCRAM-SHA-512.
+func WithSASL(mechanism string, username string, password string) Option {
+	return func(o *configOptions) {
+		o.security.SASLMechanism = mechanism
+		o.security.Username = username
+		o.security.Password = password
+	}
+}
+
+// WithCAFile verifies the brokers' TLS certificates against the CA
+// certificates in the given PEM file.
+func WithCAFile(path string) Option {
+	return func(o *configOptions) {
+		o.security.CAFile = path
+	}
+}
+
+// WithConfigMap passes arbitrary librdkafka properties to every client:
+// producers, consumers, admin clients and ClientConfig. They override the
+// settings derived from the other options.
+func WithConfigMap(configMap ckafka.ConfigMap) Option {
+	return func(o *configOptions) {
+		o.configMap = mergeConfigMaps(o.configMap, configMap)
+	}
+}
+
+// WithProducerConfigMap passes librdkafka properties to producers only, e.g.
+// compression.type, linger.ms or batch.size.
+func WithProducerConfigMap(configMap ckafka.ConfigMap) Option {
+	return func(o *configOptions) {
+		o.producerConfigMap = mergeConfigMaps(o.producerConfigMap, configMap)
+	}
+}
+
+// WithConsumerConfigMap passes librdkafka properties to consumers only, e.g.
+// fetch.min.bytes or session.timeout.ms.
+func WithConsumerConfigMap(configMap ckafka.ConfigMap) Option {
+	return func(o *configOptions) {
+		o.consumerConfigMap = mergeConfigMaps(o.consumerConfigMap, configMap)
+	}
+}
+
+// WithSerializer sets how Publish encodes values, plain JSON by default.
+// Setting it drops the default JSON Content-Type header: messages encoded
+// with the serializer carry its content type instead.
+func WithSerializer(serializer Serializer) Option {
+	return func(o *configOptions) {
+		o.serializer = serializer
+	}
+}
+
+// WithDeserializer decodes messages whose Content-Type is contentType with
+// deserializer. Messages with a JSON Content-Type, or none, are decoded as
+// plain JSON unless another deserializer is registered for them.
+func WithDeserializer(contentType string, deserializer Deserializer) Option {
+	return func(o *configOptions) {
+		if o.deserializers == nil {
+			o.deserializers = make(map[string]Deserializer)
+		}
+		o.deserializers[contentType] = deserializer
+	}
+}
+
+// WithSchemaRegistryDeserializer decodes the Avro, Protobuf and JSON Schema
+// content types with deserializer, as well as any other message whose
+// payload is in the Confluent wire format.
+func WithSchemaRegistryDeserializer(deserializer *SchemaRegistryDeserializer) Option {
+	return func(o *configOptions) {
+		for _, contentType := range []string{ContentTypeAvro, ContentTypeProtobuf, ContentTypeJSONSchema} {
+			WithDeserializer(contentType, deserializer)(o)
+		}
+		o.wireFormat = deserializer
+	}
+}
+
 func newConfig(opts ...Option) *Config {
 	options := &configOptions{
 		clientID:          defaultClientID,
 		groupID:           defaultGroupID,
 		partitions:        defaultPartitions,
 		replicationFactor: defaultReplicationFactor,
+		autoOffsetReset:   defaultAutoOffsetReset,
 		environment:       "",
-		headers: []ckafka.Header{
-			{Key: "Content-Type", Value: []byte("application/json")},
-		},
 	}
 
 	for _, opt := range opts {
@@ -111,15 +271,51 @@ func newConfig(opts ...Option) *Config {
 		logger = &logging.NoOpLogger{}
 	}
 
+	// Messages are labelled as JSON by default. With another serializer the
+	// Content-Type is left to the messages it encodes.
+	headers := options.headers
+	serializer := options.serializer
+	if serializer == nil {
+		serializer = JSONSerializer{}
+		headers = append([]ckafka.Header{{Key: HeaderContentType, Value: []byte(ContentTypeJSON)}}, headers...)
+	}
+
+	appName := options.appName
+	if appName == "" {
+		appName = options.clientID
+	}
+
+	idGenerator := options.idGenerator
+	if idGenerator == nil {
+		idGenerator = helper.NewIDGenerator(0, "")
+	}
+
 	return &Config{
-		Brokers:           options.brokers,
-		ClientID:          options.clientID,
-		GroupID:           options.groupID,
-		Environment:       options.environment,
-		Topics:            options.topics,
-		Partitions:        options.partitions,
-		ReplicationFactor: options.replicationFactor,
-		Logger:            logger,
+		Brokers:                options.brokers,
+		ClientID:               options.clientID,
+		AppName:                appName,
+		GroupID:                options.groupID,
+		Environment:            options.environment,
+		TopicPrefix:            expandTopicTemplate(options.topicPrefix, options.environment, appName),
+		TopicSuffix:            expandTopicTemplate(options.topicSuffix, options.environment, appName),
+		Topics:                 options.topics,
+		Partitions:             options.partitions,
+		ReplicationFactor:      options.replicationFactor,
+		Logger:                 logger,
+		Headers:                headers,
+		IDGenerator:            idGenerator,
+		Idempotence:            options.idempotence || options.transactionalID != "",
+		TransactionalID:        options.transactionalID,
+		RebalanceCallback:      options.rebalanceCallback,
+		ReaderFactory:          options.readerFactory,
+		AutoOffsetReset:        options.autoOffsetReset,
+		Security:               options.security,
+		ConfigMap:              options.configMap,
+		ProducerConfigMap:      options.producerConfigMap,
+		ConsumerConfigMap:      options.consumerConfigMap,
+		Serializer:             serializer,
+		Deserializers:          options.deserializers,
+		WireFormatDeserializer: options.wireFormat,
 	}
 }
 
@@ -129,35 +325,19 @@ func WithHeaders(headers []ckafka.Header) Option {
 	}
 }
 
-func ensureTopics(cfg *Config, producer *ckafka.Producer, consumer *ckafka.Consumer) error {
-
-	var admin *ckafka.AdminClient
-	var err error
-	if producer != nil {
-		admin, err = ckafka.NewAdminClientFromProducer(producer)
-	} else {
-		admin, err = ckafka.NewAdminClientFromConsumer(consumer)
+func ensureTopics(cfg *Config, topicNames []string, producer *ckafka.Producer, consumer *ckafka.Consumer) error {
+	if len(topicNames) == 0 {
+		return nil
 	}
+
+	admin, err := newAdminFrom(cfg, producer, consumer)
 	if err != nil {
 		return err
 	}
 	defer admin.Close()
 
-	if len(cfg.Topics) == 0 {
-		return nil
-	}
-
-	topics := lo.Map(cfg.Topics, func(topic string, _ int) ckafka.TopicSpecification {
-		return ckafka.TopicSpecification{
-			Topic:             topic,
-			NumPartitions:     cfg.Partitions,
-			ReplicationFactor: cfg.ReplicationFactor,
-		}
+	specs := lo.Map(topicNames, func(topic string, _ int) TopicSpec {
+		return TopicSpec{Name: cfg.TopicName(topic)}
 	})
-
-	_, err = admin.CreateTopics(context.TODO(), topics, nil)
-	if err != nil && !strings.Contains(err.Error(), "Topic already exists") {
-		return err
-	}
-	return nil
+	return admin.CreateTopics(context.TODO(), specs...)
 }
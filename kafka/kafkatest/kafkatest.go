@@ -0,0 +1,337 @@
+// Package kafkatest provides an in-memory broker for unit-testing code built
+// on the kafka package without a running Kafka cluster.
+//
+// Broker keeps every topic as a single ordered partition. Its Producer
+// implements kafka.Sender and its Consumer implements kafka.Reader, so code
+// depending on those interfaces can publish and consume in memory, inject
+// delivery errors and assert on the messages sent. With WithBroker, the
+// consumer pools of a kafka.ConsumerManager read from the broker too, so a
+// kafka.Subscriber or kafka.BatchConsumer runs without a cluster. Code that
+// needs a real client can run against MockCluster instead.
+package kafkatest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+)
+
+// Broker is an in-memory message store shared by its producers and
+// consumers.
+type Broker struct {
+	mu        sync.Mutex
+	topics    map[string][]*ckafka.Message
+	committed map[string]ckafka.Offset
+	failures  map[string][]error
+	// published is closed and replaced whenever a message is stored.
+	published chan struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		topics:    make(map[string][]*ckafka.Message),
+		committed: make(map[string]ckafka.Offset),
+		failures:  make(map[string][]error),
+		published: make(chan struct{}),
+	}
+}
+
+// FailNext makes the next send to topic fail with err. Calls queue up, so
+// calling it twice fails the next two sends.
+func (b *Broker) FailNext(topic string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures[topic] = append(b.failures[topic], err)
+}
+
+// Messages returns the messages stored in topic, in order.
+func (b *Broker) Messages(topic string) []*ckafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*ckafka.Message(nil), b.topics[topic]...)
+}
+
+// WaitForMessages waits until topic holds at least n messages and returns
+// them. It fails the test once timeout expires.
+func (b *Broker) WaitForMessages(t testing.TB, topic string, n int, timeout time.Duration) []*ckafka.Message {
+	t.Helper()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		b.mu.Lock()
+		msgs := append([]*ckafka.Message(nil), b.topics[topic]...)
+		published := b.published
+		b.mu.Unlock()
+		if len(msgs) >= n {
+			return msgs
+		}
+
+		select {
+		case <-published:
+		case <-deadline.C:
+			t.Fatalf("expected %d messages on topic %s, got %d", n, topic, len(msgs))
+			return nil
+		}
+	}
+}
+
+// store appends msg to its topic, or returns the error queued by FailNext.
+func (b *Broker) store(msg *ckafka.Message) (ckafka.TopicPartition, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topic := *msg.TopicPartition.Topic
+	if failures := b.failures[topic]; len(failures) > 0 {
+		b.failures[topic] = failures[1:]
+		tp := msg.TopicPartition
+		tp.Error = failures[0]
+		return tp, failures[0]
+	}
+
+	msg.TopicPartition.Partition = 0
+	msg.TopicPartition.Offset = ckafka.Offset(len(b.topics[topic]))
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	b.topics[topic] = append(b.topics[topic], msg)
+
+	close(b.published)
+	b.published = make(chan struct{})
+	return msg.TopicPartition, nil
+}
+
+// Producer is an in-memory kafka.Sender storing messages in its broker.
+type Producer struct {
+	broker  *Broker
+	headers []ckafka.Header
+}
+
+var _ kafka.Sender = (*Producer)(nil)
+
+// Producer returns a producer adding headers to every message, as
+// kafka.WithHeaders does for a real producer.
+func (b *Broker) Producer(headers ...ckafka.Header) *Producer {
+	return &Producer{broker: b, headers: headers}
+}
+
+// Send stores the message. Unlike a real producer, a failure injected with
+// FailNext is returned directly instead of through a delivery report.
+func (p *Producer) Send(topic string, value []byte, opts ...kafka.MessageOption) error {
+	_, err := p.send(topic, value, opts...)
+	return err
+}
+
+// SendSync stores the message and returns its offset.
+func (p *Producer) SendSync(ctx context.Context, topic string, value []byte, opts ...kafka.MessageOption) (ckafka.TopicPartition, error) {
+	if err := ctx.Err(); err != nil {
+		return ckafka.TopicPartition{}, err
+	}
+	return p.send(topic, value, opts...)
+}
+
+func (p *Producer) send(topic string, value []byte, opts ...kafka.MessageOption) (ckafka.TopicPartition, error) {
+	msg := kafka.NewMessage(topic, value, opts...)
+	msg.Headers = append(append([]ckafka.Header(nil), p.headers...), msg.Headers...)
+	return p.broker.store(msg)
+}
+
+// Consumer is an in-memory kafka.Reader reading one topic for a consumer
+// group. Consumers of the same group share committed offsets, and a new
+// consumer starts at the group's committed offset.
+type Consumer struct {
+	broker *Broker
+	key    string
+	topic  string
+	// position, paused and closed are guarded by the broker's mutex.
+	position ckafka.Offset
+	paused   bool
+	closed   bool
+}
+
+var _ kafka.Reader = (*Consumer)(nil)
+
+// Consumer returns a consumer of topic for groupID.
+func (b *Broker) Consumer(groupID string, topic string) *Consumer {
+	key := groupID + "::" + topic
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &Consumer{broker: b, key: key, topic: topic, position: b.committed[key]}
+}
+
+// WithBroker makes consumer pools read from b instead of connecting to
+// brokers, so that a kafka.Subscriber or kafka.BatchConsumer can run in
+// memory. Offsets are committed only when the consumer is told to.
+func WithBroker(b *Broker) kafka.Option {
+	return kafka.WithReaderFactory(func(groupID string, topic string, _ bool) (kafka.Reader, error) {
+		return b.Consumer(groupID, topic), nil
+	})
+}
+
+// ReadMessage returns the next message, waiting up to timeout for one to be
+// published. Like a real consumer it returns an ErrTimedOut kafka.Error when
+// none arrives, and a negative timeout waits indefinitely. A paused consumer
+// returns no message.
+func (c *Consumer) ReadMessage(timeout time.Duration) (*ckafka.Message, error) {
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		c.broker.mu.Lock()
+		if c.closed {
+			c.broker.mu.Unlock()
+			return nil, ckafka.NewError(ckafka.ErrState, "consumer is closed", false)
+		}
+		msgs := c.broker.topics[c.topic]
+		published := c.broker.published
+		if !c.paused && int(c.position) < len(msgs) {
+			msg := msgs[c.position]
+			c.position++
+			c.broker.mu.Unlock()
+			return msg, nil
+		}
+		c.broker.mu.Unlock()
+
+		select {
+		case <-published:
+		case <-expired:
+			return nil, ckafka.NewError(ckafka.ErrTimedOut, "timed out", false)
+		}
+	}
+}
+
+// CommitMessage commits the offset following msg for the consumer's group.
+func (c *Consumer) CommitMessage(msg *ckafka.Message) ([]ckafka.TopicPartition, error) {
+	tp := msg.TopicPartition
+	tp.Offset++
+	return c.CommitOffsets([]ckafka.TopicPartition{tp})
+}
+
+// CommitOffsets commits the offsets given for the consumer's topic.
+func (c *Consumer) CommitOffsets(offsets []ckafka.TopicPartition) ([]ckafka.TopicPartition, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	for _, tp := range offsets {
+		if c.owns(tp) {
+			c.broker.committed[c.key] = tp.Offset
+		}
+	}
+	return offsets, nil
+}
+
+// Seek moves the consumer to the offset of partition.
+func (c *Consumer) Seek(partition ckafka.TopicPartition, _ int) error {
+	_, err := c.SeekPartitions([]ckafka.TopicPartition{partition})
+	return err
+}
+
+// SeekPartitions moves the consumer to the offsets given for its topic.
+func (c *Consumer) SeekPartitions(partitions []ckafka.TopicPartition) ([]ckafka.TopicPartition, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	for _, tp := range partitions {
+		if c.owns(tp) {
+			c.position = tp.Offset
+		}
+	}
+	return partitions, nil
+}
+
+// Pause stops the consumer from returning messages until it is resumed.
+func (c *Consumer) Pause(partitions []ckafka.TopicPartition) error {
+	c.setPaused(partitions, true)
+	return nil
+}
+
+// Resume lets a paused consumer return messages again.
+func (c *Consumer) Resume(partitions []ckafka.TopicPartition) error {
+	c.setPaused(partitions, false)
+	return nil
+}
+
+func (c *Consumer) setPaused(partitions []ckafka.TopicPartition, paused bool) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	for _, tp := range partitions {
+		if c.owns(tp) {
+			c.paused = paused
+		}
+	}
+}
+
+// Close closes the consumer. Reading from it afterwards fails.
+func (c *Consumer) Close() error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// owns reports whether tp is the single partition of the consumer's topic.
+func (c *Consumer) owns(tp ckafka.TopicPartition) bool {
+	return tp.Topic != nil && *tp.Topic == c.topic && tp.Partition == 0
+}
+
+// Committed returns the offset committed by groupID on topic.
+func (b *Broker) Committed(groupID string, topic string) ckafka.Offset {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed[groupID+"::"+topic]
+}
+
+// Message builds a kafka.Message as a Subscriber would hand it to its
+// handler, with value encoded as JSON, for unit-testing handlers.
+func Message[T any](t testing.TB, topic string, value T, opts ...kafka.MessageOption) *kafka.Message[T] {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("failed to encode message value: %v", err)
+	}
+	opts = append(opts, kafka.WithMessageHeaders(ckafka.Header{Key: kafka.HeaderContentType, Value: []byte(kafka.ContentTypeJSON)}))
+	return &kafka.Message[T]{Value: value, Raw: kafka.NewMessage(topic, data, opts...)}
+}
+
+// Header returns the value of the last header with the given key.
+func Header(msg *ckafka.Message, key string) (string, bool) {
+	return kafka.HeaderValue(msg.Headers, key)
+}
+
+// AssertHeader fails the test unless msg carries header key with value want.
+func AssertHeader(t testing.TB, msg *ckafka.Message, key string, want string) {
+	t.Helper()
+	got, ok := Header(msg, key)
+	if !ok {
+		t.Errorf("expected header %s on message %s", key, msg.TopicPartition)
+		return
+	}
+	if got != want {
+		t.Errorf("expected header %s to be %q, got %q", key, want, got)
+	}
+}
+
+// MockCluster starts librdkafka's in-process mock cluster with the given
+// topics, of one partition each, and returns the option pointing clients at
+// it. The cluster is closed when the test ends.
+func MockCluster(t testing.TB, topics ...string) kafka.Option {
+	t.Helper()
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+
+	for _, topic := range topics {
+		if err := cluster.CreateTopic(topic, 1, 1); err != nil {
+			t.Fatalf("failed to create topic %s: %v", topic, err)
+		}
+	}
+	return kafka.WithBrokers([]string{cluster.BootstrapServers()})
+}
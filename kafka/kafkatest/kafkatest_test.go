@@ -0,0 +1,106 @@
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+)
+
+func TestBroker_ProduceConsume(t *testing.T) {
+	broker := NewBroker()
+	producer := broker.Producer(ckafka.Header{Key: kafka.HeaderEnv, Value: []byte("test")})
+	ctx := context.Background()
+
+	failure := errors.New("broker down")
+	broker.FailNext("orders", failure)
+	if _, err := producer.SendSync(ctx, "orders", []byte("lost")); !errors.Is(err, failure) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+
+	for _, value := range []string{"first", "second"} {
+		if _, err := producer.SendSync(ctx, "orders", []byte(value), kafka.WithKey([]byte("customer-1"))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	msgs := broker.Messages("orders")
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	AssertHeader(t, msgs[0], kafka.HeaderEnv, "test")
+
+	consumer := broker.Consumer("billing", "orders")
+	msg, err := consumer.ReadMessage(time.Second)
+	if err != nil || string(msg.Value) != "first" {
+		t.Fatalf("unexpected message %v, err %v", msg, err)
+	}
+	if _, err := consumer.CommitMessage(msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A new consumer of the group resumes after the committed message.
+	msg, err = broker.Consumer("billing", "orders").ReadMessage(time.Second)
+	if err != nil || string(msg.Value) != "second" {
+		t.Fatalf("unexpected message %v, err %v", msg, err)
+	}
+
+	var kerr ckafka.Error
+	if _, err := broker.Consumer("other", "empty").ReadMessage(10 * time.Millisecond); !errors.As(err, &kerr) || kerr.Code() != ckafka.ErrTimedOut {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
+
+func TestBroker_WaitForMessages(t *testing.T) {
+	broker := NewBroker()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = broker.Producer().Send("orders", []byte("late"))
+	}()
+
+	msgs := broker.WaitForMessages(t, "orders", 1, time.Second)
+	if string(msgs[0].Value) != "late" {
+		t.Fatalf("unexpected message %s", msgs[0].Value)
+	}
+}
+
+func TestWithBroker_RunsSubscriber(t *testing.T) {
+	broker := NewBroker()
+	producer := broker.Producer()
+	for _, value := range []string{"first", "second", "third"} {
+		if err := producer.Send("orders", []byte(value)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	cm := kafka.NewConsumerManager(WithBroker(broker), kafka.WithGroupID("billing"))
+	defer cm.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var handled []string
+	failed := false
+	sub := kafka.NewSubscriber(cm, "orders", func(ctx context.Context, msg *kafka.Message[string]) error {
+		// The second message fails once and is redelivered.
+		if msg.Value == "second" && !failed {
+			failed = true
+			return errors.New("boom")
+		}
+		if handled = append(handled, msg.Value); len(handled) == 3 {
+			cancel()
+		}
+		return nil
+	}, kafka.WithRetryBackoff(time.Millisecond))
+	if err := sub.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(handled) != 3 || handled[1] != "second" {
+		t.Errorf("expected every message to be handled in order, got %v", handled)
+	}
+	if offset := broker.Committed("billing", "orders"); offset != 3 {
+		t.Errorf("expected offset 3 to be committed, got %d", offset)
+	}
+}
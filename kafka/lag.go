@@ -0,0 +1,260 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/samber/lo"
+)
+
+const defaultLagInterval = 30 * time.Second
+
+// PartitionLag is the position of a consumer group on a partition.
+type PartitionLag struct {
+	Partition int32
+	// Committed is the committed offset of the group, -1 when the group has
+	// not committed on the partition yet.
+	Committed     int64
+	LowWatermark  int64
+	HighWatermark int64
+	// Lag is the number of messages the group still has to consume. Without
+	// a committed offset it counts from the low watermark.
+	Lag int64
+}
+
+// TopicLag is the position of a consumer group on every partition of a
+// topic.
+type TopicLag struct {
+	GroupID    string
+	Topic      string
+	Partitions []PartitionLag
+}
+
+// Total returns the lag summed over all partitions.
+func (l TopicLag) Total() int64 {
+	return lo.SumBy(l.Partitions, func(p PartitionLag) int64 { return p.Lag })
+}
+
+// GroupLag returns the committed offset, watermarks and lag of groupID on
+// every partition of topic.
+func (a *Admin) GroupLag(ctx context.Context, groupID string, topic string) (TopicLag, error) {
+	metadata, err := a.admin.GetMetadata(&topic, false, timeoutMs(ctx, defaultMetadataTimeout))
+	if err != nil {
+		return TopicLag{}, err
+	}
+	topicMetadata, ok := metadata.Topics[topic]
+	if !ok {
+		return TopicLag{}, fmt.Errorf("topic %s not found", topic)
+	}
+	if topicMetadata.Error.Code() != ckafka.ErrNoError {
+		return TopicLag{}, fmt.Errorf("topic %s: %w", topic, topicMetadata.Error)
+	}
+
+	partitions := lo.Map(topicMetadata.Partitions, func(p ckafka.PartitionMetadata, _ int) ckafka.TopicPartition {
+		return ckafka.TopicPartition{Topic: &topic, Partition: p.ID}
+	})
+
+	committed, err := a.admin.ListConsumerGroupOffsets(ctx, []ckafka.ConsumerGroupTopicPartitions{
+		{Group: groupID, Partitions: partitions},
+	})
+	if err != nil {
+		return TopicLag{}, err
+	}
+	committedOffsets := make(map[int32]int64, len(partitions))
+	for _, group := range committed.ConsumerGroupsTopicPartitions {
+		for _, tp := range group.Partitions {
+			if tp.Error != nil {
+				return TopicLag{}, fmt.Errorf("partition %d: %w", tp.Partition, tp.Error)
+			}
+			committedOffsets[tp.Partition] = int64(tp.Offset)
+		}
+	}
+
+	low, err := a.listOffsets(ctx, partitions, ckafka.EarliestOffsetSpec)
+	if err != nil {
+		return TopicLag{}, err
+	}
+	high, err := a.listOffsets(ctx, partitions, ckafka.LatestOffsetSpec)
+	if err != nil {
+		return TopicLag{}, err
+	}
+
+	lag := TopicLag{GroupID: groupID, Topic: topic, Partitions: make([]PartitionLag, 0, len(partitions))}
+	for _, tp := range partitions {
+		p := PartitionLag{
+			Partition:     tp.Partition,
+			Committed:     -1,
+			LowWatermark:  low[tp.Partition],
+			HighWatermark: high[tp.Partition],
+		}
+		if offset, ok := committedOffsets[tp.Partition]; ok && offset >= 0 {
+			p.Committed = offset
+		}
+		p.Lag = partitionLag(p)
+		lag.Partitions = append(lag.Partitions, p)
+	}
+	return lag, nil
+}
+
+// listOffsets returns the offset matching spec for each of partitions.
+func (a *Admin) listOffsets(ctx context.Context, partitions []ckafka.TopicPartition, spec ckafka.OffsetSpec) (map[int32]int64, error) {
+	request := make(map[ckafka.TopicPartition]ckafka.OffsetSpec, len(partitions))
+	for _, tp := range partitions {
+		request[tp] = spec
+	}
+
+	result, err := a.admin.ListOffsets(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[int32]int64, len(result.ResultInfos))
+	for tp, info := range result.ResultInfos {
+		if info.Error.Code() != ckafka.ErrNoError {
+			return nil, fmt.Errorf("partition %d: %w", tp.Partition, info.Error)
+		}
+		offsets[tp.Partition] = int64(info.Offset)
+	}
+	return offsets, nil
+}
+
+func partitionLag(p PartitionLag) int64 {
+	position := p.Committed
+	if position < 0 {
+		position = p.LowWatermark
+	}
+	return max(p.HighWatermark-position, 0)
+}
+
+// Lag returns the lag of the manager's consumer group on topic.
+func (cm *ConsumerManager) Lag(ctx context.Context, topic string) (TopicLag, error) {
+	admin, err := cm.getAdmin()
+	if err != nil {
+		return TopicLag{}, err
+	}
+	return admin.GroupLag(ctx, cm.cfg.GroupID, cm.cfg.TopicName(topic))
+}
+
+// getAdmin returns the admin client of the manager, creating it on first
+// use. It is closed by Close.
+func (cm *ConsumerManager) getAdmin() (*Admin, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.closed {
+		return nil, ErrPoolClosed
+	}
+	if cm.admin == nil {
+		admin, err := newAdmin(cm.cfg)
+		if err != nil {
+			return nil, err
+		}
+		cm.admin = admin
+	}
+	return cm.admin, nil
+}
+
+type lagReporterOptions struct {
+	interval time.Duration
+	callback func(TopicLag)
+}
+
+// LagReporterOption configures a LagReporter.
+type LagReporterOption func(*lagReporterOptions)
+
+// WithLagInterval sets how often the lag is collected, 30s by default.
+func WithLagInterval(interval time.Duration) LagReporterOption {
+	return func(o *lagReporterOptions) {
+		o.interval = interval
+	}
+}
+
+// WithLagCallback sets a func called with the lag of each topic every time it
+// is collected, e.g. to export it as a metric.
+func WithLagCallback(callback func(TopicLag)) LagReporterOption {
+	return func(o *lagReporterOptions) {
+		o.callback = callback
+	}
+}
+
+// LagReporter periodically collects the lag of a consumer group, logs it and
+// keeps the latest values for health endpoints.
+type LagReporter struct {
+	cm     *ConsumerManager
+	topics []string
+	opts   lagReporterOptions
+
+	mu       sync.RWMutex
+	snapshot map[string]TopicLag
+}
+
+// NewLagReporter creates a reporter for the lag of cm's consumer group on
+// topics.
+func NewLagReporter(cm *ConsumerManager, topics []string, opts ...LagReporterOption) *LagReporter {
+	options := lagReporterOptions{interval: defaultLagInterval}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &LagReporter{
+		cm:       cm,
+		topics:   topics,
+		opts:     options,
+		snapshot: make(map[string]TopicLag),
+	}
+}
+
+// Run collects the lag right away and then at every interval until ctx is
+// done. Collection errors are logged and do not stop the reporter.
+func (r *LagReporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		r.collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot returns the latest lag of each topic, in the order the topics
+// were given. Topics whose lag was never collected are omitted.
+func (r *LagReporter) Snapshot() []TopicLag {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := make([]TopicLag, 0, len(r.snapshot))
+	for _, topic := range r.topics {
+		if lag, ok := r.snapshot[topic]; ok {
+			snapshot = append(snapshot, lag)
+		}
+	}
+	return snapshot
+}
+
+func (r *LagReporter) collect(ctx context.Context) {
+	for _, topic := range r.topics {
+		lag, err := r.cm.Lag(ctx, topic)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.cm.logger.Errorf("Failed to get lag of topic %s: %v", topic, err)
+			}
+			continue
+		}
+
+		r.mu.Lock()
+		r.snapshot[topic] = lag
+		r.mu.Unlock()
+
+		r.cm.logger.Infof("Consumer group %s lag on topic %s: %d", lag.GroupID, topic, lag.Total())
+		if r.opts.callback != nil {
+			r.opts.callback(lag)
+		}
+	}
+}
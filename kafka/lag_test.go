@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestPartitionLag(t *testing.T) {
+	tests := []struct {
+		name string
+		lag  PartitionLag
+		want int64
+	}{
+		{"committed", PartitionLag{Committed: 7, LowWatermark: 0, HighWatermark: 10}, 3},
+		{"not committed", PartitionLag{Committed: -1, LowWatermark: 4, HighWatermark: 10}, 6},
+		{"caught up", PartitionLag{Committed: 10, LowWatermark: 0, HighWatermark: 10}, 0},
+		{"committed past retention", PartitionLag{Committed: 12, LowWatermark: 0, HighWatermark: 10}, 0},
+	}
+	for _, tt := range tests {
+		if got := partitionLag(tt.lag); got != tt.want {
+			t.Errorf("%s: expected lag %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestConsumerManager_Lag(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("orders", 2, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := NewProducer(brokers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if _, err := producer.SendSync(ctx, "orders", []byte("order"), WithPartition(0)); err != nil {
+			t.Fatalf("unexpected error sending: %v", err)
+		}
+	}
+
+	cm := NewConsumerManager(brokers, WithGroupID("lag-group"))
+	defer cm.Close(ctx)
+
+	lag, err := cm.Lag(ctx, "orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lag.Partitions) != 2 {
+		t.Fatalf("expected 2 partitions, got %+v", lag.Partitions)
+	}
+	if lag.Total() != 3 {
+		t.Errorf("expected total lag 3, got %d (%+v)", lag.Total(), lag.Partitions)
+	}
+	for _, p := range lag.Partitions {
+		if p.Committed != -1 {
+			t.Errorf("expected no committed offset on partition %d, got %d", p.Partition, p.Committed)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type messageOptions struct {
+	key       []byte
+	partition int32
+	timestamp time.Time
+	headers   []ckafka.Header
+	// physicalTopic sends to the topic as named, without the namespace.
+	physicalTopic bool
+}
+
+type MessageOption func(*messageOptions)
+
+// WithKey sets the message key. Messages with the same key land on the same
+// partition, which preserves their order.
+func WithKey(key []byte) MessageOption {
+	return func(o *messageOptions) {
+		o.key = key
+	}
+}
+
+// WithPartition sends the message to an explicit partition instead of letting
+// the partitioner choose one.
+func WithPartition(partition int32) MessageOption {
+	return func(o *messageOptions) {
+		o.partition = partition
+	}
+}
+
+// WithTimestamp sets the message timestamp. It defaults to the produce time.
+func WithTimestamp(timestamp time.Time) MessageOption {
+	return func(o *messageOptions) {
+		o.timestamp = timestamp
+	}
+}
+
+// WithMessageHeaders adds headers to the message. They are sent after the
+// producer's default and environment headers, and replace the defaults with
+// the same key.
+func WithMessageHeaders(headers ...ckafka.Header) MessageOption {
+	return func(o *messageOptions) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+// withHeader sets a header of the message, replacing the headers with the
+// same key added by earlier options.
+func withHeader(key string, value []byte) MessageOption {
+	return func(o *messageOptions) {
+		o.headers = append(withoutHeaders(o.headers, key), ckafka.Header{Key: key, Value: value})
+	}
+}
+
+// withPhysicalTopic sends the message to the topic as named, for topic names
+// that are already namespaced such as a request's reply-to header.
+func withPhysicalTopic() MessageOption {
+	return func(o *messageOptions) {
+		o.physicalTopic = true
+	}
+}
+
+func newMessageOptions(opts []MessageOption) *messageOptions {
+	options := &messageOptions{
+		partition: ckafka.PartitionAny,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// NewMessage builds the message that Send would produce from value and
+// opts, without the producer's default and environment headers.
+func NewMessage(topic string, value []byte, opts ...MessageOption) *ckafka.Message {
+	return newMessage(topic, value, newMessageOptions(opts))
+}
+
+func newMessage(topic string, value []byte, options *messageOptions) *ckafka.Message {
+	return &ckafka.Message{
+		TopicPartition: ckafka.TopicPartition{
+			Topic:     &topic,
+			Partition: options.partition,
+		},
+		Value:     value,
+		Key:       options.key,
+		Timestamp: options.timestamp,
+		Headers:   options.headers,
+	}
+}
+
+// buildMessage assembles the message sent to topic.
+func (p *Producer) buildMessage(topic string, value []byte, opts ...MessageOption) *ckafka.Message {
+	options := newMessageOptions(opts)
+	if !options.physicalTopic {
+		topic = p.cfg.TopicName(topic)
+	}
+	msg := newMessage(topic, value, options)
+	msg.Headers = p.mergeHeaders(msg.Headers)
+	return msg
+}
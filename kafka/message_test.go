@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestBuildMessage_Defaults(t *testing.T) {
+	p := &Producer{cfg: newConfig(WithEnvironment("dev"))}
+
+	msg := p.buildMessage("orders", []byte(`{}`))
+	if *msg.TopicPartition.Topic != "orders" {
+		t.Errorf("expected topic 'orders', got '%s'", *msg.TopicPartition.Topic)
+	}
+	if msg.TopicPartition.Partition != ckafka.PartitionAny {
+		t.Errorf("expected PartitionAny, got %d", msg.TopicPartition.Partition)
+	}
+	if msg.Key != nil {
+		t.Errorf("expected no key, got '%s'", msg.Key)
+	}
+	if v, _ := HeaderValue(msg.Headers, HeaderContentType); v != ContentTypeJSON {
+		t.Errorf("expected Content-Type '%s', got '%s'", ContentTypeJSON, v)
+	}
+	if v, _ := HeaderValue(msg.Headers, HeaderEnv); v != "dev" {
+		t.Errorf("expected env 'dev', got '%s'", v)
+	}
+}
+
+func TestBuildMessage_Options(t *testing.T) {
+	p := &Producer{cfg: newConfig()}
+	ts := time.Unix(1700000000, 0)
+
+	msg := p.buildMessage("orders", []byte(`{}`),
+		WithKey([]byte("order-1")),
+		WithPartition(3),
+		WithTimestamp(ts),
+		WithMessageHeaders(ckafka.Header{Key: "trace", Value: []byte("abc")}),
+	)
+	if string(msg.Key) != "order-1" {
+		t.Errorf("expected key 'order-1', got '%s'", msg.Key)
+	}
+	if msg.TopicPartition.Partition != 3 {
+		t.Errorf("expected partition 3, got %d", msg.TopicPartition.Partition)
+	}
+	if !msg.Timestamp.Equal(ts) {
+		t.Errorf("expected timestamp %v, got %v", ts, msg.Timestamp)
+	}
+	if v, _ := HeaderValue(msg.Headers, "trace"); v != "abc" {
+		t.Errorf("expected trace header 'abc', got '%s'", v)
+	}
+}
+
+func TestMergeHeaders_DoesNotShareDefaults(t *testing.T) {
+	p := &Producer{cfg: newConfig(WithEnvironment("dev"))}
+
+	first := p.mergeHeaders([]ckafka.Header{{Key: "a", Value: []byte("1")}})
+	second := p.mergeHeaders([]ckafka.Header{{Key: "b", Value: []byte("2")}})
+
+	if _, ok := HeaderValue(first, "b"); ok {
+		t.Error("headers of one message leaked into another")
+	}
+	if _, ok := HeaderValue(second, "a"); ok {
+		t.Error("headers of one message leaked into another")
+	}
+	if len(p.cfg.Headers) != 1 {
+		t.Errorf("expected default headers to stay untouched, got %d", len(p.cfg.Headers))
+	}
+}
+
+func TestMergeHeaders_SkipsExistingKeys(t *testing.T) {
+	p := &Producer{cfg: newConfig(WithEnvironment("dev"))}
+
+	headers := p.mergeHeaders([]ckafka.Header{
+		{Key: HeaderContentType, Value: []byte(ContentTypeAvro)},
+		{Key: HeaderEnv, Value: []byte("prod")},
+		{Key: HeaderRetryAttempt, Value: []byte("1")},
+	})
+	if len(headers) != 3 {
+		t.Fatalf("expected the defaults to be skipped, got %v", headers)
+	}
+	if v, _ := HeaderValue(headers, HeaderContentType); v != ContentTypeAvro {
+		t.Errorf("expected Content-Type '%s', got '%s'", ContentTypeAvro, v)
+	}
+	if v, _ := HeaderValue(headers, HeaderEnv); v != "prod" {
+		t.Errorf("expected env 'prod', got '%s'", v)
+	}
+}
+
+func TestNewConfig_SerializerDropsJSONContentType(t *testing.T) {
+	cfg := newConfig(WithSerializer(stubSerializer{contentType: ContentTypeAvro}))
+	if _, ok := HeaderValue(cfg.Headers, HeaderContentType); ok {
+		t.Errorf("expected no default Content-Type with a serializer, got %v", cfg.Headers)
+	}
+}
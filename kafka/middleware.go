@@ -0,0 +1,206 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// MessageHandler handles a raw message polled from a ConsumerPool consumer.
+type MessageHandler func(ctx context.Context, msg *ckafka.Message) error
+
+// Middleware wraps a MessageHandler with cross-cutting behaviour.
+type Middleware func(next MessageHandler) MessageHandler
+
+// Chain wraps handler with middlewares. The first middleware is the
+// outermost one and sees every message first.
+func Chain(handler MessageHandler, middlewares ...Middleware) MessageHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// WithMiddleware runs every message through middlewares before it is decoded
+// and handed to the subscriber's handler.
+func WithMiddleware(middlewares ...Middleware) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// PanicError is the error Recover returns for a handler panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func recovered(value any) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+// Recover turns a handler panic into a PanicError, logged with its stack,
+// so the message goes through the usual failure handling.
+func Recover(logger logging.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					panicErr := recovered(v)
+					logger.Errorf("Recovered panic handling message %s: %v\n%s", msg.TopicPartition, v, panicErr.Stack)
+					err = panicErr
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// RecoverToDeadLetter sends a message whose handler panicked straight to the
+// dead-letter topic of its original topic, published with producer, instead
+// of retrying it. The message is then considered handled.
+func RecoverToDeadLetter(producer *Producer, logger logging.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) (err error) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				panicErr := recovered(v)
+				logger.Errorf("Recovered panic handling message %s: %v\n%s", msg.TopicPartition, v, panicErr.Stack)
+
+				topic, ok := HeaderValue(msg.Headers, HeaderOriginalTopic)
+				if !ok {
+					topic = *msg.TopicPartition.Topic
+				}
+				topic = producer.cfg.LogicalTopic(topic)
+				dlq := &retrier{producer: producer, policy: RetryPolicy{DeadLetter: true}, topic: topic}
+				if _, ferr := dlq.forward(ctx, msg, panicErr, false); ferr != nil {
+					err = errors.Join(panicErr, fmt.Errorf("failed to send message to dead-letter topic: %w", ferr))
+					return
+				}
+				err = nil
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Metrics calls observe with the outcome and duration of every message, e.g.
+// to feed a histogram.
+func Metrics(observe func(msg *ckafka.Message, duration time.Duration, err error)) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			observe(msg, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// HeaderCarrier adapts message headers to an OpenTelemetry TextMapCarrier.
+type HeaderCarrier struct {
+	Headers *[]ckafka.Header
+}
+
+func (c HeaderCarrier) Get(key string) string {
+	value, _ := HeaderValue(*c.Headers, key)
+	return value
+}
+
+func (c HeaderCarrier) Set(key string, value string) {
+	*c.Headers = append(withoutHeaders(*c.Headers, key), ckafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, len(*c.Headers))
+	for i, h := range *c.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+var defaultPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// TraceContext extracts the trace context carried in the message headers
+// into the handler context. It understands W3C traceparent and baggage
+// headers unless other propagators are given.
+func TraceContext(propagators ...propagation.TextMapPropagator) Middleware {
+	propagator := defaultPropagator
+	if len(propagators) > 0 {
+		propagator = propagation.NewCompositeTextMapPropagator(propagators...)
+	}
+
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) error {
+			ctx = propagator.Extract(ctx, HeaderCarrier{Headers: &msg.Headers})
+			return next(ctx, msg)
+		}
+	}
+}
+
+// WithTraceContext injects the trace context of ctx into the message
+// headers, so consumers using TraceContext continue the trace.
+func WithTraceContext(ctx context.Context) MessageOption {
+	return func(o *messageOptions) {
+		defaultPropagator.Inject(ctx, HeaderCarrier{Headers: &o.headers})
+	}
+}
+
+// LogFields identify a message in logs.
+type LogFields struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       string
+}
+
+func (f LogFields) String() string {
+	return fmt.Sprintf("topic=%s partition=%d offset=%d key=%s", f.Topic, f.Partition, f.Offset, f.Key)
+}
+
+type logFieldsKey struct{}
+
+// LogFieldsFromContext returns the fields of the message being handled,
+// stored by the Logging middleware.
+func LogFieldsFromContext(ctx context.Context) (LogFields, bool) {
+	fields, ok := ctx.Value(logFieldsKey{}).(LogFields)
+	return fields, ok
+}
+
+// Logging stores the message's LogFields in the handler context and logs the
+// outcome of every message with them.
+func Logging(logger logging.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) error {
+			fields := LogFields{
+				Topic:     *msg.TopicPartition.Topic,
+				Partition: msg.TopicPartition.Partition,
+				Offset:    int64(msg.TopicPartition.Offset),
+				Key:       string(msg.Key),
+			}
+			ctx = context.WithValue(ctx, logFieldsKey{}, fields)
+
+			start := time.Now()
+			err := next(ctx, msg)
+			if err != nil {
+				logger.Errorf("Failed to handle message in %s: %v [%s]", time.Since(start), err, fields)
+				return err
+			}
+			logger.Debugf("Handled message in %s [%s]", time.Since(start), fields)
+			return nil
+		}
+	}
+}
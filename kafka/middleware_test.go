@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(ctx context.Context, msg *ckafka.Message) error {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		calls = append(calls, "handler")
+		return nil
+	}, record("first"), record("second"))
+
+	if err := handler(context.Background(), testMessage("orders", 0, 1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "first,second,handler" {
+		t.Fatalf("unexpected call order %s", got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		panic("boom")
+	}, Recover(&logging.NoOpLogger{}))
+
+	err := handler(context.Background(), testMessage("orders", 0, 1))
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+	if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("unexpected panic error %+v", panicErr)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	failure := errors.New("boom")
+	var observed error
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		return failure
+	}, Metrics(func(msg *ckafka.Message, duration time.Duration, err error) {
+		observed = err
+	}))
+
+	if err := handler(context.Background(), testMessage("orders", 0, 1)); !errors.Is(err, failure) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(observed, failure) {
+		t.Fatalf("expected the handler error to be observed, got %v", observed)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	options := &messageOptions{}
+	WithTraceContext(trace.ContextWithSpanContext(context.Background(), spanCtx))(options)
+
+	msg := testMessage("orders", 0, 1)
+	msg.Headers = options.headers
+
+	var extracted trace.SpanContext
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		extracted = trace.SpanContextFromContext(ctx)
+		return nil
+	}, TraceContext())
+
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if extracted.TraceID() != spanCtx.TraceID() || extracted.SpanID() != spanCtx.SpanID() || !extracted.IsRemote() {
+		t.Fatalf("unexpected span context %+v", extracted)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	msg := testMessage("orders", 3, 42)
+	msg.Key = []byte("customer-1")
+
+	var fields LogFields
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		fields, _ = LogFieldsFromContext(ctx)
+		return nil
+	}, Logging(&logging.NoOpLogger{}))
+
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := LogFields{Topic: "orders", Partition: 3, Offset: 42, Key: "customer-1"}
+	if fields != want {
+		t.Fatalf("expected %+v, got %+v", want, fields)
+	}
+}
@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+)
+
+// Placeholders expanded in topic naming templates.
+const (
+	TopicEnvPlaceholder = "{env}"
+	TopicAppPlaceholder = "{app}"
+)
+
+// WithAppName sets the application name expanded for {app} in topic naming
+// templates, the client ID by default.
+func WithAppName(appName string) Option {
+	return func(o *configOptions) {
+		o.appName = appName
+	}
+}
+
+// WithTopicNaming namespaces topics by wrapping every topic name with prefix
+// and suffix, in which {env} and {app} are replaced with the environment and
+// the application name, e.g. WithTopicNaming("{env}.", "") to share a
+// cluster between environments.
+//
+// Code keeps using the plain topic names: the namespaced names are applied
+// when sending, subscribing, creating topics and deriving retry and
+// dead-letter topics.
+func WithTopicNaming(prefix string, suffix string) Option {
+	return func(o *configOptions) {
+		o.topicPrefix = prefix
+		o.topicSuffix = suffix
+	}
+}
+
+func expandTopicTemplate(template string, env string, app string) string {
+	return strings.NewReplacer(TopicEnvPlaceholder, env, TopicAppPlaceholder, app).Replace(template)
+}
+
+// TopicName returns the namespaced name of topic.
+func (c *Config) TopicName(topic string) string {
+	if c.TopicPrefix == "" && c.TopicSuffix == "" {
+		return topic
+	}
+	return c.TopicPrefix + topic + c.TopicSuffix
+}
+
+// LogicalTopic strips the namespace added by TopicName from name. Names
+// outside the namespace are returned unchanged.
+func (c *Config) LogicalTopic(name string) string {
+	if !strings.HasPrefix(name, c.TopicPrefix) || !strings.HasSuffix(name, c.TopicSuffix) ||
+		len(name) < len(c.TopicPrefix)+len(c.TopicSuffix) {
+		return name
+	}
+	return name[len(c.TopicPrefix) : len(name)-len(c.TopicSuffix)]
+}
+
+// WithEnvironmentFilter drops messages whose env header names another
+// environment than the consumer manager's, see EnvironmentFilter.
+func WithEnvironmentFilter() SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.envFilter = true
+	}
+}
+
+// EnvironmentFilter drops messages whose env header is set to another
+// environment than env. Dropped messages are not handled but are committed.
+// Messages without an env header are handled.
+func EnvironmentFilter(env string, logger logging.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, msg *ckafka.Message) error {
+			if msgEnv, ok := HeaderValue(msg.Headers, HeaderEnv); ok && msgEnv != env {
+				logger.Debugf("Dropping message %s from environment %s", msg.TopicPartition, msgEnv)
+				return nil
+			}
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+)
+
+func TestConfigTopicName(t *testing.T) {
+	cfg := newConfig(WithEnvironment("staging"), WithClientID("billing"), WithTopicNaming("{env}.", ".{app}"))
+	if got := cfg.TopicName("orders"); got != "staging.orders.billing" {
+		t.Fatalf("unexpected topic name %s", got)
+	}
+	if got := cfg.TopicName(RetryTopic("orders", 1)); got != "staging.orders.retry.1.billing" {
+		t.Fatalf("unexpected retry topic name %s", got)
+	}
+	if got := cfg.LogicalTopic("staging.orders.billing"); got != "orders" {
+		t.Fatalf("unexpected logical topic %s", got)
+	}
+	if got := cfg.LogicalTopic("orders"); got != "orders" {
+		t.Fatalf("expected names outside the namespace to be unchanged, got %s", got)
+	}
+
+	plain := newConfig(WithEnvironment("staging"))
+	if got := plain.TopicName("orders"); got != "orders" {
+		t.Fatalf("expected topics without naming to be unchanged, got %s", got)
+	}
+}
+
+func TestEnvironmentFilter(t *testing.T) {
+	handled := 0
+	handler := Chain(func(ctx context.Context, msg *ckafka.Message) error {
+		handled++
+		return nil
+	}, EnvironmentFilter("staging", &logging.NoOpLogger{}))
+
+	for _, env := range []string{"staging", "dev", ""} {
+		msg := testMessage("orders", 0, 1)
+		if env != "" {
+			msg.Headers = []ckafka.Header{{Key: HeaderEnv, Value: []byte(env)}}
+		}
+		if err := handler(context.Background(), msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if handled != 2 {
+		t.Fatalf("expected the foreign message to be dropped, handled %d", handled)
+	}
+}
+
+func TestTopicNaming_MockCluster(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("staging.orders", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	opts := []Option{
+		WithBrokers([]string{cluster.BootstrapServers()}),
+		WithEnvironment("staging"),
+		WithTopicNaming("{env}.", ""),
+	}
+	producer, err := NewProducer(opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	tp, err := producer.SendSync(ctx, "orders", []byte("order"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *tp.Topic != "staging.orders" {
+		t.Fatalf("expected message on the namespaced topic, got %s", *tp.Topic)
+	}
+
+	cm := NewConsumerManager(append(opts, WithGroupID("naming-group"))...)
+	defer cm.Close(context.Background())
+	consumer, release, err := cm.GetPool("orders").Borrow()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+	msg, err := consumer.ReadMessage(10 * time.Second)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	if string(msg.Value) != "order" {
+		t.Fatalf("unexpected message %s", msg.Value)
+	}
+}
@@ -3,10 +3,10 @@ package kafka
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/samber/lo"
 )
 
 type Producer struct {
@@ -22,16 +22,13 @@ func NewProducer(opts ...Option) (*Producer, error) {
 		return nil, fmt.Errorf("kafka brokers are required")
 	}
 
-	producer, err := ckafka.NewProducer(&ckafka.ConfigMap{
-		"bootstrap.servers": strings.Join(cfg.Brokers, ","),
-		"client.id":         cfg.ClientID,
-	})
+	producer, err := ckafka.NewProducer(cfg.producerConfig())
 	if err != nil {
 		cfg.Logger.Errorf("Failed to create kafka producer: %v", err)
 		return nil, err
 	}
 
-	if err := ensureTopics(cfg, producer, nil); err != nil {
+	if err := ensureTopics(cfg, cfg.Topics, producer, nil); err != nil {
 		cfg.Logger.Errorf("Failed to ensure topics: %v", err)
 		// Note: Topic creation failure is logged but doesn't prevent producer creation
 	}
@@ -41,59 +38,119 @@ func NewProducer(opts ...Option) (*Producer, error) {
 		cfg:      cfg,
 		logger:   cfg.Logger,
 	}
+	go p.handleEvents()
+
+	if cfg.TransactionalID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTransactionTimeout)
+		defer cancel()
+		if err := producer.InitTransactions(ctx); err != nil {
+			cfg.Logger.Errorf("Failed to init kafka transactions: %v", err)
+			producer.Close()
+			return nil, err
+		}
+	}
 
 	return p, nil
 }
 
 func (p *Producer) SendMessage(topic string, message []byte, headers []ckafka.Header) error {
-	finalHeaders := p.mergeHeaders(headers)
-	return p.producer.Produce(&ckafka.Message{
-		TopicPartition: ckafka.TopicPartition{
-			Topic:     &topic,
-			Partition: ckafka.PartitionAny,
-		},
-		Value:   message,
-		Headers: finalHeaders,
-	}, nil)
+	return p.Send(topic, message, WithMessageHeaders(headers...))
 }
 
-func (p *Producer) EnsureTopics(topics []string, partitions int, replicationFactor int) error {
-	admin, err := ckafka.NewAdminClientFromProducer(p.producer)
-	if err != nil {
-		return err
+// SendMessageSync sends a message and waits for its delivery report. It
+// returns the partition and offset assigned by the broker, or the delivery
+// error. If ctx is done before the report arrives, ctx.Err() is returned and
+// the message may still be delivered.
+func (p *Producer) SendMessageSync(ctx context.Context, topic string, message []byte, headers []ckafka.Header) (ckafka.TopicPartition, error) {
+	return p.SendSync(ctx, topic, message, WithMessageHeaders(headers...))
+}
+
+// Send sends a message built from value and opts without waiting for its
+// delivery report.
+func (p *Producer) Send(topic string, value []byte, opts ...MessageOption) error {
+	return p.producer.Produce(p.buildMessage(topic, value, opts...), nil)
+}
+
+// SendSync is the delivery-confirmed variant of Send, see SendMessageSync.
+func (p *Producer) SendSync(ctx context.Context, topic string, value []byte, opts ...MessageOption) (ckafka.TopicPartition, error) {
+	deliveryChan := make(chan ckafka.Event, 1)
+	if err := p.producer.Produce(p.buildMessage(topic, value, opts...), deliveryChan); err != nil {
+		return ckafka.TopicPartition{}, err
 	}
-	defer admin.Close()
 
-	specs := make([]ckafka.TopicSpecification, len(topics))
-	for i, topic := range topics {
-		specs[i] = ckafka.TopicSpecification{
-			Topic:             topic,
-			NumPartitions:     partitions,
-			ReplicationFactor: replicationFactor,
+	select {
+	case <-ctx.Done():
+		return ckafka.TopicPartition{}, ctx.Err()
+	case ev := <-deliveryChan:
+		msg, ok := ev.(*ckafka.Message)
+		if !ok {
+			return ckafka.TopicPartition{}, fmt.Errorf("unexpected delivery event: %v", ev)
 		}
+		return msg.TopicPartition, msg.TopicPartition.Error
 	}
+}
 
-	_, err = admin.CreateTopics(context.TODO(), specs, nil)
-	if err != nil && !strings.Contains(err.Error(), "Topic already exists") {
+func (p *Producer) EnsureTopics(topics []string, partitions int, replicationFactor int) error {
+	admin, err := newAdminFrom(p.cfg, p.producer, nil)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer admin.Close()
+
+	specs := lo.Map(topics, func(topic string, _ int) TopicSpec {
+		return TopicSpec{Name: p.cfg.TopicName(topic), Partitions: partitions, ReplicationFactor: replicationFactor}
+	})
+	return admin.CreateTopics(context.TODO(), specs...)
+}
+
+// Admin returns an admin client sharing the producer's connection. The
+// caller must close it.
+func (p *Producer) Admin() (*Admin, error) {
+	return newAdminFrom(p.cfg, p.producer, nil)
 }
 
 func (p *Producer) Close() {
 	p.producer.Close()
 }
 
+// handleEvents drains the producer's event channel, logging the delivery
+// reports of messages sent with SendMessage and client-level errors. It
+// returns once the producer is closed.
+func (p *Producer) handleEvents() {
+	for ev := range p.producer.Events() {
+		switch e := ev.(type) {
+		case *ckafka.Message:
+			if e.TopicPartition.Error != nil {
+				p.logger.Errorf("Failed to deliver message to %s: %v", e.TopicPartition, e.TopicPartition.Error)
+			} else {
+				p.logger.Debugf("Delivered message to %s", e.TopicPartition)
+			}
+		case ckafka.Error:
+			p.logger.Errorf("Kafka producer error: %v", e)
+		}
+	}
+}
+
+// mergeHeaders prepends the producer's default and environment headers to
+// the headers of a message. Defaults whose key the message already carries
+// are left out, so forwarded messages and explicit headers never end up with
+// two values for the same key.
 func (p *Producer) mergeHeaders(headers []ckafka.Header) []ckafka.Header {
-	defaultHeaders := p.cfg.Headers
-	if env := p.cfg.Environment; env != "" {
-		defaultHeaders = append(defaultHeaders, ckafka.Header{
-			Key:   "env",
-			Value: []byte(env),
-		})
+	// Copy the defaults so concurrent sends never append into the shared
+	// backing array of cfg.Headers.
+	finalHeaders := make([]ckafka.Header, 0, len(p.cfg.Headers)+len(headers)+1)
+	for _, h := range p.cfg.Headers {
+		if _, ok := HeaderValue(headers, h.Key); !ok {
+			finalHeaders = append(finalHeaders, h)
+		}
 	}
-	if len(headers) == 0 {
-		return defaultHeaders
+	if env := p.cfg.Environment; env != "" {
+		if _, ok := HeaderValue(headers, HeaderEnv); !ok {
+			finalHeaders = append(finalHeaders, ckafka.Header{
+				Key:   HeaderEnv,
+				Value: []byte(env),
+			})
+		}
 	}
-	return append(defaultHeaders, headers...)
+	return append(finalHeaders, headers...)
 }
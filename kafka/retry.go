@@ -0,0 +1,175 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// RetryPolicy configures what a Subscriber does with messages whose handler
+// fails.
+type RetryPolicy struct {
+	// Backoffs holds the delay before each retry. Retry n is published to
+	// RetryTopic(topic, n) and handled once Backoffs[n-1] has elapsed.
+	Backoffs []time.Duration
+	// DeadLetter publishes messages that exhausted their retries, or could
+	// not be decoded, to DeadLetterTopic(topic). Without it those messages
+	// are logged and dropped.
+	DeadLetter bool
+}
+
+// RetryTopic returns the name of the topic holding the n-th retry of messages
+// from topic.
+func RetryTopic(topic string, n int) string {
+	return fmt.Sprintf("%s.retry.%d", topic, n)
+}
+
+// DeadLetterTopic returns the name of the dead-letter topic of topic.
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+type retrier struct {
+	producer *Producer
+	policy   RetryPolicy
+	topic    string
+}
+
+func (r *retrier) retryTopics() []string {
+	topics := make([]string, len(r.policy.Backoffs))
+	for i := range r.policy.Backoffs {
+		topics[i] = RetryTopic(r.topic, i+1)
+	}
+	return topics
+}
+
+func (r *retrier) ensureTopics() error {
+	topics := r.retryTopics()
+	if r.policy.DeadLetter {
+		topics = append(topics, DeadLetterTopic(r.topic))
+	}
+	return ensureTopics(r.producer.cfg, topics, r.producer.producer, nil)
+}
+
+// forward publishes a failed message to the next retry topic, or to the
+// dead-letter topic once retries are exhausted or retryable is false. It
+// returns the topic the message was sent to, or "" when it was dropped.
+func (r *retrier) forward(ctx context.Context, msg *ckafka.Message, cause error, retryable bool) (string, error) {
+	attempt := retryAttempt(msg) + 1
+
+	headers := withoutHeaders(msg.Headers, HeaderRetryAttempt, HeaderRetryAt, HeaderLastError)
+	if _, ok := HeaderValue(headers, HeaderOriginalTopic); !ok {
+		headers = append(headers,
+			ckafka.Header{Key: HeaderOriginalTopic, Value: []byte(*msg.TopicPartition.Topic)},
+			ckafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Partition), 10))},
+			ckafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+		)
+	}
+	headers = append(headers,
+		ckafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(attempt))},
+		ckafka.Header{Key: HeaderLastError, Value: []byte(cause.Error())},
+	)
+
+	var topic string
+	switch {
+	case retryable && attempt <= len(r.policy.Backoffs):
+		topic = RetryTopic(r.topic, attempt)
+		retryAt := time.Now().Add(r.policy.Backoffs[attempt-1])
+		headers = append(headers, ckafka.Header{
+			Key:   HeaderRetryAt,
+			Value: []byte(strconv.FormatInt(retryAt.UnixMilli(), 10)),
+		})
+	case r.policy.DeadLetter:
+		topic = DeadLetterTopic(r.topic)
+	default:
+		return "", nil
+	}
+
+	if _, err := r.producer.SendSync(ctx, topic, msg.Value, WithKey(msg.Key), WithMessageHeaders(headers...)); err != nil {
+		return "", err
+	}
+	return topic, nil
+}
+
+// retryAttempt returns how many times msg has already been retried.
+func retryAttempt(msg *ckafka.Message) int {
+	value, ok := HeaderValue(msg.Headers, HeaderRetryAttempt)
+	if !ok {
+		return 0
+	}
+	attempt, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return attempt
+}
+
+// retryAt returns when a message read from a retry topic becomes due.
+func retryAt(msg *ckafka.Message) (time.Time, bool) {
+	value, ok := HeaderValue(msg.Headers, HeaderRetryAt)
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// delayedPartitions tracks retry-topic partitions paused until their next
+// message becomes due. Pausing instead of sleeping keeps the consumer polling
+// so it is not evicted from the group during long backoffs.
+type delayedPartitions map[string]delayedPartition
+
+type delayedPartition struct {
+	partition ckafka.TopicPartition
+	until     time.Time
+}
+
+// delay pauses the partition of msg and rewinds it so msg is fetched again
+// once the partition is resumed.
+func (d delayedPartitions) delay(consumer Reader, msg *ckafka.Message, until time.Time) error {
+	tp := ckafka.TopicPartition{Topic: msg.TopicPartition.Topic, Partition: msg.TopicPartition.Partition}
+	if err := consumer.Pause([]ckafka.TopicPartition{tp}); err != nil {
+		return err
+	}
+	if err := consumer.Seek(msg.TopicPartition, 0); err != nil {
+		return err
+	}
+	d[tp.String()] = delayedPartition{partition: tp, until: until}
+	return nil
+}
+
+// resumeDue resumes every partition whose delay has elapsed.
+func (d delayedPartitions) resumeDue(consumer Reader) error {
+	now := time.Now()
+	for key, p := range d {
+		if now.Before(p.until) {
+			continue
+		}
+		delete(d, key)
+		if err := consumer.Resume([]ckafka.TopicPartition{p.partition}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeAll resumes every delayed partition, so a consumer given back to its
+// pool does not keep partitions paused for the next borrower. Their messages
+// were rewound by delay and are fetched again.
+func (d delayedPartitions) resumeAll(consumer Reader) error {
+	if len(d) == 0 {
+		return nil
+	}
+	partitions := make([]ckafka.TopicPartition, 0, len(d))
+	for key, p := range d {
+		partitions = append(partitions, p.partition)
+		delete(d, key)
+	}
+	return consumer.Resume(partitions)
+}
@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestRetryTopicNames(t *testing.T) {
+	if got := RetryTopic("orders", 2); got != "orders.retry.2" {
+		t.Errorf("expected 'orders.retry.2', got '%s'", got)
+	}
+	if got := DeadLetterTopic("orders"); got != "orders.dlq" {
+		t.Errorf("expected 'orders.dlq', got '%s'", got)
+	}
+
+	r := &retrier{topic: "orders", policy: RetryPolicy{Backoffs: []time.Duration{time.Second, time.Minute}}}
+	topics := r.retryTopics()
+	if len(topics) != 2 || topics[0] != "orders.retry.1" || topics[1] != "orders.retry.2" {
+		t.Errorf("unexpected retry topics: %v", topics)
+	}
+}
+
+func TestRetryHeaders(t *testing.T) {
+	msg := &ckafka.Message{}
+	if got := retryAttempt(msg); got != 0 {
+		t.Errorf("expected attempt 0 without header, got %d", got)
+	}
+	if _, ok := retryAt(msg); ok {
+		t.Error("expected no retry time without header")
+	}
+
+	due := time.UnixMilli(1700000000000)
+	msg.Headers = []ckafka.Header{
+		{Key: HeaderRetryAttempt, Value: []byte("1")},
+		{Key: HeaderRetryAttempt, Value: []byte("2")},
+		{Key: HeaderRetryAt, Value: []byte("1700000000000")},
+	}
+	if got := retryAttempt(msg); got != 2 {
+		t.Errorf("expected attempt 2, got %d", got)
+	}
+	if got, ok := retryAt(msg); !ok || !got.Equal(due) {
+		t.Errorf("expected retry time %v, got %v", due, got)
+	}
+}
+
+// newTestRetrier returns a retrier for topic "orders" publishing to a mock
+// cluster, and a func reading back the message sent to a partition.
+func newTestRetrier(t *testing.T, policy RetryPolicy) (*retrier, func(tp ckafka.TopicPartition) *ckafka.Message) {
+	t.Helper()
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	for _, topic := range []string{"orders.retry.1", "orders.retry.2", "orders.dlq"} {
+		if err := cluster.CreateTopic(topic, 1, 1); err != nil {
+			t.Fatalf("failed to create topic: %v", err)
+		}
+	}
+
+	producer, err := NewProducer(WithBrokers([]string{cluster.BootstrapServers()}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(producer.Close)
+
+	read := func(tp ckafka.TopicPartition) *ckafka.Message {
+		t.Helper()
+		consumer, err := ckafka.NewConsumer(&ckafka.ConfigMap{
+			"bootstrap.servers": cluster.BootstrapServers(),
+			"group.id":          "retry-reader",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer consumer.Close()
+		if err := consumer.Assign([]ckafka.TopicPartition{tp}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		msg, err := consumer.ReadMessage(10 * time.Second)
+		if err != nil {
+			t.Fatalf("failed to read forwarded message: %v", err)
+		}
+		return msg
+	}
+	return &retrier{producer: producer, policy: policy, topic: "orders"}, read
+}
+
+// forwardAndRead forwards msg and reads it back from the topic it was sent to.
+func forwardAndRead(t *testing.T, r *retrier, read func(ckafka.TopicPartition) *ckafka.Message, msg *ckafka.Message, cause string, retryable bool, want string) *ckafka.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	topic, err := r.forward(ctx, msg, errors.New(cause), retryable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if topic != want {
+		t.Fatalf("expected message to be forwarded to %s, got %q", want, topic)
+	}
+	return read(ckafka.TopicPartition{Topic: &topic, Partition: 0, Offset: ckafka.OffsetBeginning})
+}
+
+func countHeaders(msg *ckafka.Message, key string) int {
+	n := 0
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			n++
+		}
+	}
+	return n
+}
+
+func assertHeader(t *testing.T, msg *ckafka.Message, key string, want string) {
+	t.Helper()
+	if n := countHeaders(msg, key); n != 1 {
+		t.Errorf("expected one %s header, got %d", key, n)
+	}
+	if got, _ := HeaderValue(msg.Headers, key); got != want {
+		t.Errorf("expected header %s to be %q, got %q", key, want, got)
+	}
+}
+
+func TestRetrier_ForwardsThroughRetriesToDeadLetter(t *testing.T) {
+	r, read := newTestRetrier(t, RetryPolicy{Backoffs: []time.Duration{time.Second, time.Minute}, DeadLetter: true})
+
+	msg := testMessage("orders", 3, 7)
+	msg.Key = []byte("customer-1")
+	msg.Value = []byte("order")
+
+	first := forwardAndRead(t, r, read, msg, "first failure", true, "orders.retry.1")
+	assertHeader(t, first, HeaderOriginalTopic, "orders")
+	assertHeader(t, first, HeaderOriginalPartition, "3")
+	assertHeader(t, first, HeaderOriginalOffset, "7")
+	assertHeader(t, first, HeaderRetryAttempt, "1")
+	assertHeader(t, first, HeaderLastError, "first failure")
+	if due, ok := retryAt(first); !ok || time.Until(due) <= 0 {
+		t.Errorf("expected the retry to be due after the backoff, got %v", due)
+	}
+	if string(first.Key) != "customer-1" || string(first.Value) != "order" {
+		t.Errorf("expected key and value to be kept, got %s=%s", first.Key, first.Value)
+	}
+
+	second := forwardAndRead(t, r, read, first, "second failure", true, "orders.retry.2")
+	assertHeader(t, second, HeaderOriginalTopic, "orders")
+	assertHeader(t, second, HeaderOriginalOffset, "7")
+	assertHeader(t, second, HeaderRetryAttempt, "2")
+	assertHeader(t, second, HeaderLastError, "second failure")
+	if n := countHeaders(second, HeaderRetryAt); n != 1 {
+		t.Errorf("expected the retry time to be replaced, got %d headers", n)
+	}
+
+	dead := forwardAndRead(t, r, read, second, "third failure", true, "orders.dlq")
+	assertHeader(t, dead, HeaderOriginalTopic, "orders")
+	assertHeader(t, dead, HeaderRetryAttempt, "3")
+	assertHeader(t, dead, HeaderLastError, "third failure")
+	if n := countHeaders(dead, HeaderRetryAt); n != 0 {
+		t.Errorf("expected no retry time on the dead-letter message, got %d", n)
+	}
+}
+
+func TestRetrier_NotRetryableGoesToDeadLetter(t *testing.T) {
+	r, read := newTestRetrier(t, RetryPolicy{Backoffs: []time.Duration{time.Second}, DeadLetter: true})
+
+	dead := forwardAndRead(t, r, read, testMessage("orders", 0, 1), "cannot decode", false, "orders.dlq")
+	assertHeader(t, dead, HeaderRetryAttempt, "1")
+	assertHeader(t, dead, HeaderLastError, "cannot decode")
+}
+
+func TestRetrier_DropsWithoutDeadLetter(t *testing.T) {
+	r := &retrier{policy: RetryPolicy{Backoffs: []time.Duration{time.Second}}, topic: "orders"}
+	ctx := context.Background()
+
+	// No message is sent, so no producer is needed.
+	if topic, err := r.forward(ctx, testMessage("orders", 0, 1), errors.New("cannot decode"), false); err != nil || topic != "" {
+		t.Errorf("expected a non-retryable message to be dropped, got %q, %v", topic, err)
+	}
+	exhausted := testMessage("orders.retry.1", 0, 1)
+	exhausted.Headers = []ckafka.Header{{Key: HeaderRetryAttempt, Value: []byte("1")}}
+	if topic, err := r.forward(ctx, exhausted, errors.New("boom"), true); err != nil || topic != "" {
+		t.Errorf("expected a message out of retries to be dropped, got %q, %v", topic, err)
+	}
+}
+
+// pausingReader records the partitions paused, resumed and sought.
+type pausingReader struct {
+	Reader
+	paused  []ckafka.TopicPartition
+	resumed []ckafka.TopicPartition
+	sought  []ckafka.TopicPartition
+}
+
+func (r *pausingReader) Pause(partitions []ckafka.TopicPartition) error {
+	r.paused = append(r.paused, partitions...)
+	return nil
+}
+
+func (r *pausingReader) Resume(partitions []ckafka.TopicPartition) error {
+	r.resumed = append(r.resumed, partitions...)
+	return nil
+}
+
+func (r *pausingReader) Seek(partition ckafka.TopicPartition, _ int) error {
+	r.sought = append(r.sought, partition)
+	return nil
+}
+
+func TestDelayedPartitions(t *testing.T) {
+	reader := &pausingReader{}
+	delayed := delayedPartitions{}
+
+	later := testMessage("orders.retry.1", 0, 4)
+	due := testMessage("orders.retry.1", 1, 9)
+	if err := delayed.delay(reader, later, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := delayed.delay(reader, due, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reader.paused) != 2 || len(reader.sought) != 2 || reader.sought[0].Offset != 4 || reader.sought[1].Offset != 9 {
+		t.Fatalf("expected both partitions to be paused and rewound, got paused %v, sought %v", reader.paused, reader.sought)
+	}
+
+	if err := delayed.resumeDue(reader); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reader.resumed) != 1 || reader.resumed[0].Partition != 1 {
+		t.Fatalf("expected only the due partition to be resumed, got %v", reader.resumed)
+	}
+
+	if err := delayed.resumeAll(reader); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reader.resumed) != 2 || reader.resumed[1].Partition != 0 || len(delayed) != 0 {
+		t.Errorf("expected the remaining partition to be resumed, got %v", reader.resumed)
+	}
+}
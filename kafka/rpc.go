@@ -0,0 +1,259 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+)
+
+// Headers of request and reply messages.
+const (
+	HeaderCorrelationID = "correlation-id"
+	HeaderReplyTo       = "reply-to"
+	HeaderReplyError    = "x-reply-error"
+)
+
+// ErrRequesterClosed is returned by Request once the requester is closed.
+var ErrRequesterClosed = errors.New("kafka requester is closed")
+
+// RemoteError is returned by Request when the responder's handler failed.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "kafka request failed: " + e.Message
+}
+
+// Requester sends requests to a topic and waits for their replies on a reply
+// topic.
+//
+// Every request carries a correlation-id header, generated with the
+// producer's IDGenerator, and a reply-to header naming the reply topic as it
+// exists on the cluster, with the requester's topic namespace applied. The
+// requester reads every partition of the reply topic with its own consumer
+// group, so each instance sees the replies to its own requests; replies to
+// other instances are ignored.
+type Requester struct {
+	producer   *Producer
+	consumer   *ckafka.Consumer
+	replyTopic string
+	// replyTo is the namespaced name of replyTopic.
+	replyTo string
+	logger  logging.Logger
+
+	mu      sync.Mutex
+	pending map[string]chan *ckafka.Message
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRequester creates a requester publishing with producer and reading
+// replies from replyTopic, which must exist. Only replies produced after
+// NewRequester returns are read.
+func NewRequester(producer *Producer, replyTopic string) (*Requester, error) {
+	cfg := producer.cfg
+	groupID := cfg.GroupID + "-reply-" + cfg.IDGenerator.GenerateID()
+	consumer, err := ckafka.NewConsumer(cfg.consumerConfig(groupID, false))
+	if err != nil {
+		return nil, err
+	}
+	replyTo := cfg.TopicName(replyTopic)
+	if err := assignLatest(consumer, replyTo); err != nil {
+		_ = consumer.Close()
+		return nil, fmt.Errorf("failed to assign reply topic %s: %w", replyTopic, err)
+	}
+
+	r := &Requester{
+		producer:   producer,
+		consumer:   consumer,
+		replyTopic: replyTopic,
+		replyTo:    replyTo,
+		logger:     producer.logger,
+		pending:    make(map[string]chan *ckafka.Message),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go r.readReplies()
+	return r, nil
+}
+
+// assignLatest assigns every partition of topic to consumer at its current
+// high watermark.
+func assignLatest(consumer *ckafka.Consumer, topic string) error {
+	metadata, err := consumer.GetMetadata(&topic, false, int(defaultMetadataTimeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	meta, ok := metadata.Topics[topic]
+	if ok && meta.Error.Code() != ckafka.ErrNoError {
+		return meta.Error
+	}
+	if !ok || len(meta.Partitions) == 0 {
+		return fmt.Errorf("topic %s not found", topic)
+	}
+
+	partitions := make([]ckafka.TopicPartition, 0, len(meta.Partitions))
+	for _, p := range meta.Partitions {
+		_, high, err := consumer.QueryWatermarkOffsets(topic, p.ID, int(defaultMetadataTimeout.Milliseconds()))
+		if err != nil {
+			return err
+		}
+		partitions = append(partitions, ckafka.TopicPartition{Topic: &topic, Partition: p.ID, Offset: ckafka.Offset(high)})
+	}
+	return consumer.Assign(partitions)
+}
+
+// readReplies hands replies to the pending requests until the requester is
+// closed.
+func (r *Requester) readReplies() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		switch e := r.consumer.Poll(int(defaultPollTimeout.Milliseconds())).(type) {
+		case *ckafka.Message:
+			id, ok := HeaderValue(e.Headers, HeaderCorrelationID)
+			if !ok {
+				continue
+			}
+			r.mu.Lock()
+			reply, ok := r.pending[id]
+			delete(r.pending, id)
+			r.mu.Unlock()
+			if ok {
+				reply <- e
+			}
+		case ckafka.Error:
+			r.logger.Errorf("Failed to read replies from topic %s: %v", r.replyTopic, e)
+		}
+	}
+}
+
+// Request sends value to topic and waits for the reply until ctx is done.
+// It returns a RemoteError when the responder's handler failed.
+func (r *Requester) Request(ctx context.Context, topic string, value []byte, opts ...MessageOption) (*ckafka.Message, error) {
+	id := r.producer.cfg.IDGenerator.GenerateID()
+	reply := make(chan *ckafka.Message, 1)
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRequesterClosed
+	}
+	r.pending[id] = reply
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	opts = append(opts, WithMessageHeaders(
+		ckafka.Header{Key: HeaderCorrelationID, Value: []byte(id)},
+		ckafka.Header{Key: HeaderReplyTo, Value: []byte(r.replyTo)},
+	))
+	if _, err := r.producer.SendSync(ctx, topic, value, opts...); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no reply to request %s: %w", id, ctx.Err())
+	case <-r.done:
+		return nil, ErrRequesterClosed
+	case msg := <-reply:
+		if cause, ok := HeaderValue(msg.Headers, HeaderReplyError); ok {
+			return nil, &RemoteError{Message: cause}
+		}
+		return msg, nil
+	}
+}
+
+// Close stops reading replies and closes the reply consumer. Pending
+// requests fail with ErrRequesterClosed.
+func (r *Requester) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.stop)
+	<-r.done
+	return r.consumer.Close()
+}
+
+// Call encodes req with the producer's Serializer, sends it to topic and
+// decodes the reply into Resp.
+func Call[Req any, Resp any](ctx context.Context, r *Requester, topic string, req Req, opts ...MessageOption) (Resp, error) {
+	var resp Resp
+	serializer := r.producer.cfg.Serializer
+	data, err := serializer.Serialize(r.producer.cfg.TopicName(topic), req)
+	if err != nil {
+		return resp, fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	opts = append(opts, withHeader(HeaderContentType, []byte(serializer.ContentType())))
+	msg, err := r.Request(ctx, topic, data, opts...)
+	if err != nil {
+		return resp, err
+	}
+	return decodeValue[Resp](r.producer.cfg, msg)
+}
+
+// RespondHandler serves a request and returns the reply.
+type RespondHandler[Req any, Resp any] func(ctx context.Context, msg *Message[Req]) (Resp, error)
+
+// NewResponder creates a subscriber serving the requests sent to topic by a
+// Requester. Replies are encoded with the producer's Serializer and sent to
+// the request's reply-to topic as named, without applying the responder's
+// topic namespace, so requesters and responders may use different ones.
+//
+// An error returned by handler is sent back to the requester as a
+// RemoteError instead of being retried. Requests without a reply-to header
+// are handled without replying.
+func NewResponder[Req any, Resp any](cm *ConsumerManager, producer *Producer, topic string, handler RespondHandler[Req, Resp], opts ...SubscriberOption) *Subscriber[Req] {
+	return NewSubscriber(cm, topic, func(ctx context.Context, msg *Message[Req]) error {
+		resp, err := handler(ctx, msg)
+
+		replyTo, ok := msg.Header(HeaderReplyTo)
+		if !ok {
+			return nil
+		}
+		id, _ := msg.Header(HeaderCorrelationID)
+		replyOpts := []MessageOption{
+			withPhysicalTopic(),
+			WithMessageHeaders(ckafka.Header{Key: HeaderCorrelationID, Value: []byte(id)}),
+		}
+
+		var data []byte
+		if err != nil {
+			replyOpts = append(replyOpts, WithMessageHeaders(ckafka.Header{Key: HeaderReplyError, Value: []byte(err.Error())}))
+		} else {
+			serializer := producer.cfg.Serializer
+			data, err = serializer.Serialize(replyTo, resp)
+			if err != nil {
+				return fmt.Errorf("failed to serialize reply: %w", err)
+			}
+			replyOpts = append(replyOpts, withHeader(HeaderContentType, []byte(serializer.ContentType())))
+		}
+
+		if _, err := producer.SendSync(ctx, replyTo, data, replyOpts...); err != nil {
+			return fmt.Errorf("failed to send reply to %s: %w", replyTo, err)
+		}
+		return nil
+	}, opts...)
+}
@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestRequester_Call(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	for _, topic := range []string{"quotes", "quotes.replies"} {
+		if err := cluster.CreateTopic(topic, 2, 1); err != nil {
+			t.Fatalf("failed to create topic: %v", err)
+		}
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := NewProducer(brokers, WithGroupID("quote-client"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	cm := NewConsumerManager(brokers, WithGroupID("quote-service"))
+	defer cm.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	responder := NewResponder(cm, producer, "quotes", func(ctx context.Context, msg *Message[testEvent]) (testEvent, error) {
+		if msg.Value.ID == "" {
+			return testEvent{}, errors.New("missing id")
+		}
+		return testEvent{ID: msg.Value.ID, Name: "quote for " + msg.Value.Name}, nil
+	})
+	go responder.Run(ctx)
+
+	requester, err := NewRequester(producer, "quotes.replies")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer requester.Close()
+
+	resp, err := Call[testEvent, testEvent](ctx, requester, "quotes", testEvent{ID: "1", Name: "widget"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "1" || resp.Name != "quote for widget" {
+		t.Fatalf("unexpected reply %+v", resp)
+	}
+
+	_, err = Call[testEvent, testEvent](ctx, requester, "quotes", testEvent{Name: "widget"})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Message != "missing id" {
+		t.Fatalf("expected remote error, got %v", err)
+	}
+
+	timeoutCtx, stop := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer stop()
+	if _, err := requester.Request(timeoutCtx, "unanswered", []byte("{}")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRequester_ReplyToNamespacedTopic(t *testing.T) {
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	for _, topic := range []string{"client.quotes", "client.quotes.replies"} {
+		if err := cluster.CreateTopic(topic, 1, 1); err != nil {
+			t.Fatalf("failed to create topic: %v", err)
+		}
+	}
+
+	brokers := WithBrokers([]string{cluster.BootstrapServers()})
+	client, err := NewProducer(brokers, WithGroupID("quote-client"), WithTopicNaming("client.", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	// The responder namespaces its own topics differently: the reply must
+	// still reach the requester's reply topic.
+	service, err := NewProducer(brokers, WithAppName("quote-service"), WithTopicNaming("{app}.", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer service.Close()
+	cm := NewConsumerManager(brokers, WithGroupID("quote-service"))
+	defer cm.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	responder := NewResponder(cm, service, "client.quotes", func(ctx context.Context, msg *Message[testEvent]) (testEvent, error) {
+		return testEvent{ID: msg.Value.ID}, nil
+	})
+	go responder.Run(ctx)
+
+	requester, err := NewRequester(client, "quotes.replies")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer requester.Close()
+
+	resp, err := Call[testEvent, testEvent](ctx, requester, "quotes", testEvent{ID: "1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "1" {
+		t.Fatalf("unexpected reply %+v", resp)
+	}
+}
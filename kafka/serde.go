@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry/serde"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry/serde/avro"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry/serde/jsonschema"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry/serde/protobuf"
+)
+
+// Content types of the serializers in this package. The schema registry
+// formats are sent in the Confluent wire format: a zero magic byte and the
+// 4-byte big-endian schema ID, followed by the encoded value.
+const (
+	ContentTypeJSON       = "application/json"
+	ContentTypeAvro       = "application/vnd.confluent.avro"
+	ContentTypeProtobuf   = "application/vnd.confluent.protobuf"
+	ContentTypeJSONSchema = "application/vnd.confluent.jsonschema"
+)
+
+// Schema types reported by the schema registry.
+const (
+	schemaTypeAvro     = "AVRO"
+	schemaTypeProtobuf = "PROTOBUF"
+	schemaTypeJSON     = "JSON"
+)
+
+// ErrNotWireFormat is returned when a payload does not start with the
+// Confluent wire format header.
+var ErrNotWireFormat = errors.New("payload is not in the schema registry wire format")
+
+// Serializer encodes the values sent by Publish.
+type Serializer interface {
+	// ContentType is sent in the Content-Type header of every message.
+	ContentType() string
+	// Serialize encodes value for topic, the topic name as written to the
+	// cluster with any WithTopicNaming namespace applied.
+	Serialize(topic string, value any) ([]byte, error)
+}
+
+// Deserializer decodes message values into the value v points to.
+type Deserializer interface {
+	DeserializeInto(topic string, payload []byte, v any) error
+}
+
+// JSONSerializer encodes values as plain JSON. It is the default serializer.
+type JSONSerializer struct{}
+
+func (JSONSerializer) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONSerializer) Serialize(_ string, value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// JSONDeserializer decodes plain JSON values. It is used for messages with a
+// JSON Content-Type, or none.
+type JSONDeserializer struct{}
+
+func (JSONDeserializer) DeserializeInto(_ string, payload []byte, v any) error {
+	return json.Unmarshal(payload, v)
+}
+
+type schemaRegistryOptions struct {
+	username string
+	password string
+}
+
+type SchemaRegistryOption func(*schemaRegistryOptions)
+
+// WithSchemaRegistryBasicAuth authenticates to the schema registry with the
+// given credentials.
+func WithSchemaRegistryBasicAuth(username string, password string) SchemaRegistryOption {
+	return func(o *schemaRegistryOptions) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// NewSchemaRegistryClient creates a client for the Confluent-compatible schema
+// registry at url. Schemas and IDs are cached by the client.
+func NewSchemaRegistryClient(url string, opts ...SchemaRegistryOption) (schemaregistry.Client, error) {
+	options := &schemaRegistryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	conf := schemaregistry.NewConfig(url)
+	if options.username != "" {
+		conf = schemaregistry.NewConfigWithBasicAuthentication(url, options.username, options.password)
+	}
+	return schemaregistry.NewClient(conf)
+}
+
+// registrySerializer adapts the serializers of the schemaregistry package.
+type registrySerializer struct {
+	contentType string
+	serializer  interface {
+		Serialize(topic string, msg interface{}) ([]byte, error)
+	}
+}
+
+func (s *registrySerializer) ContentType() string {
+	return s.contentType
+}
+
+func (s *registrySerializer) Serialize(topic string, value any) ([]byte, error) {
+	return s.serializer.Serialize(topic, value)
+}
+
+// NewAvroSerializer creates a serializer that encodes Go structs as Avro,
+// registering their schema under the "<topic>-value" subject.
+func NewAvroSerializer(client schemaregistry.Client) (Serializer, error) {
+	s, err := avro.NewGenericSerializer(client, serde.ValueSerde, avro.NewSerializerConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &registrySerializer{contentType: ContentTypeAvro, serializer: s}, nil
+}
+
+// NewProtobufSerializer creates a serializer for protobuf messages,
+// registering their schema under the "<topic>-value" subject.
+func NewProtobufSerializer(client schemaregistry.Client) (Serializer, error) {
+	s, err := protobuf.NewSerializer(client, serde.ValueSerde, protobuf.NewSerializerConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &registrySerializer{contentType: ContentTypeProtobuf, serializer: s}, nil
+}
+
+// NewJSONSchemaSerializer creates a serializer that encodes values as JSON,
+// registering a JSON Schema reflected from their type under the
+// "<topic>-value" subject.
+func NewJSONSchemaSerializer(client schemaregistry.Client) (Serializer, error) {
+	s, err := jsonschema.NewSerializer(client, serde.ValueSerde, jsonschema.NewSerializerConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &registrySerializer{contentType: ContentTypeJSONSchema, serializer: s}, nil
+}
+
+// SchemaRegistryDeserializer decodes values in the Confluent wire format. It
+// looks up the schema by the ID in the payload and decodes the value as Avro,
+// Protobuf or JSON Schema according to the type of the schema.
+type SchemaRegistryDeserializer struct {
+	client   schemaregistry.Client
+	avro     Deserializer
+	protobuf Deserializer
+	json     Deserializer
+}
+
+// NewSchemaRegistryDeserializer creates a deserializer that looks schemas up
+// with client.
+func NewSchemaRegistryDeserializer(client schemaregistry.Client) (*SchemaRegistryDeserializer, error) {
+	avroDeserializer, err := avro.NewGenericDeserializer(client, serde.ValueSerde, avro.NewDeserializerConfig())
+	if err != nil {
+		return nil, err
+	}
+	protobufDeserializer, err := protobuf.NewDeserializer(client, serde.ValueSerde, protobuf.NewDeserializerConfig())
+	if err != nil {
+		return nil, err
+	}
+	jsonDeserializer, err := jsonschema.NewDeserializer(client, serde.ValueSerde, jsonschema.NewDeserializerConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SchemaRegistryDeserializer{
+		client:   client,
+		avro:     avroDeserializer,
+		protobuf: protobufDeserializer,
+		json:     jsonDeserializer,
+	}, nil
+}
+
+func (d *SchemaRegistryDeserializer) DeserializeInto(topic string, payload []byte, v any) error {
+	id, err := schemaID(payload)
+	if err != nil {
+		return err
+	}
+	info, err := d.client.GetBySubjectAndID("", id)
+	if err != nil {
+		return fmt.Errorf("failed to get schema %d: %w", id, err)
+	}
+
+	switch info.SchemaType {
+	case "", schemaTypeAvro:
+		return d.avro.DeserializeInto(topic, payload, v)
+	case schemaTypeProtobuf:
+		return d.protobuf.DeserializeInto(topic, payload, v)
+	case schemaTypeJSON:
+		return d.json.DeserializeInto(topic, payload, v)
+	default:
+		return fmt.Errorf("unsupported schema type %q of schema %d", info.SchemaType, id)
+	}
+}
+
+// schemaID returns the schema ID of a payload in the Confluent wire format.
+func schemaID(payload []byte) (int, error) {
+	if len(payload) < 5 || payload[0] != 0 {
+		return 0, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(payload[1:5])), nil
+}
+
+// deserializer picks the Deserializer of msg: the one registered for its
+// Content-Type, the wire format deserializer when the payload is in the
+// Confluent wire format, and plain JSON for JSON content types.
+func (c *Config) deserializer(msg *ckafka.Message) (Deserializer, error) {
+	contentType, _ := HeaderValue(msg.Headers, HeaderContentType)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if d, ok := c.Deserializers[mediaType]; ok {
+			return d, nil
+		}
+	}
+	if c.WireFormatDeserializer != nil {
+		if _, err := schemaID(msg.Value); err == nil {
+			return c.WireFormatDeserializer, nil
+		}
+	}
+	if isJSONContentType(contentType) {
+		return JSONDeserializer{}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type %q", contentType)
+}
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type registeredSchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+// newTestSchemaRegistry starts a minimal schema registry that supports
+// registering schemas and looking them up by ID.
+func newTestSchemaRegistry(t *testing.T) schemaregistry.Client {
+	t.Helper()
+
+	var mu sync.Mutex
+	var schemas []registeredSchema
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/versions"):
+			var schema registeredSchema
+			if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			schemas = append(schemas, schema)
+			fmt.Fprintf(w, `{"id":%d}`, len(schemas))
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/schemas/ids/"):
+			var id int
+			fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/schemas/ids/"), "%d", &id)
+			if id < 1 || id > len(schemas) {
+				http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(schemas[id-1])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewSchemaRegistryClient(server.URL)
+	if err != nil {
+		t.Fatalf("failed to create schema registry client: %v", err)
+	}
+	return client
+}
+
+func serializedMessage(t *testing.T, serializer Serializer, topic string, value any) *ckafka.Message {
+	t.Helper()
+	payload, err := serializer.Serialize(topic, value)
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	if _, err := schemaID(payload); err != nil {
+		t.Fatalf("expected wire format payload: %v", err)
+	}
+	return &ckafka.Message{
+		TopicPartition: ckafka.TopicPartition{Topic: &topic},
+		Value:          payload,
+		Headers:        []ckafka.Header{{Key: HeaderContentType, Value: []byte(serializer.ContentType())}},
+	}
+}
+
+type avroOrder struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+}
+
+func TestSchemaRegistrySerde(t *testing.T) {
+	client := newTestSchemaRegistry(t)
+	deserializer, err := NewSchemaRegistryDeserializer(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg := newConfig(WithSchemaRegistryDeserializer(deserializer))
+
+	avroSerializer, err := NewAvroSerializer(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	order, err := decodeValue[avroOrder](cfg, serializedMessage(t, avroSerializer, "orders", &avroOrder{ID: "o-1", Amount: 42}))
+	if err != nil {
+		t.Fatalf("unexpected avro error: %v", err)
+	}
+	if order.ID != "o-1" || order.Amount != 42 {
+		t.Errorf("unexpected avro value: %+v", order)
+	}
+
+	jsonSerializer, err := NewJSONSchemaSerializer(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	event, err := decodeValue[testEvent](cfg, serializedMessage(t, jsonSerializer, "events", &testEvent{ID: "e-1", Name: "created"}))
+	if err != nil {
+		t.Fatalf("unexpected json schema error: %v", err)
+	}
+	if event.ID != "e-1" || event.Name != "created" {
+		t.Errorf("unexpected json schema value: %+v", event)
+	}
+
+	protobufSerializer, err := NewProtobufSerializer(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msg := serializedMessage(t, protobufSerializer, "names", wrapperspb.String("wello"))
+	msg.Headers = nil // rely on the wire format alone
+	name, err := decodeValue[*wrapperspb.StringValue](cfg, msg)
+	if err != nil {
+		t.Fatalf("unexpected protobuf error: %v", err)
+	}
+	if name.GetValue() != "wello" {
+		t.Errorf("unexpected protobuf value: %v", name)
+	}
+}
+
+func TestConfigDeserializer(t *testing.T) {
+	custom := JSONDeserializer{}
+	cfg := newConfig(WithDeserializer("application/x-custom", custom))
+
+	tests := []struct {
+		name        string
+		contentType string
+		value       []byte
+		wantErr     bool
+	}{
+		{"registered content type", "application/x-custom; v=1", []byte("{}"), false},
+		{"json", ContentTypeJSON, []byte("{}"), false},
+		{"no content type", "", []byte("{}"), false},
+		{"wire format without registry", ContentTypeAvro, []byte{0, 0, 0, 0, 1, 2}, true},
+	}
+	for _, tt := range tests {
+		msg := &ckafka.Message{Value: tt.value}
+		if tt.contentType != "" {
+			msg.Headers = []ckafka.Header{{Key: HeaderContentType, Value: []byte(tt.contentType)}}
+		}
+		if _, err := cfg.deserializer(msg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
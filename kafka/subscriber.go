@@ -0,0 +1,361 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+)
+
+const (
+	defaultPollTimeout  = 100 * time.Millisecond
+	defaultRetryBackoff = time.Second
+)
+
+// Message is a kafka message whose value has been decoded into T.
+type Message[T any] struct {
+	Value T
+	Raw   *ckafka.Message
+}
+
+// Header returns the value of the header with the given key.
+func (m *Message[T]) Header(key string) (string, bool) {
+	return HeaderValue(m.Raw.Headers, key)
+}
+
+// Handler processes a decoded message. Returning an error prevents the
+// message offset from being committed.
+type Handler[T any] func(ctx context.Context, msg *Message[T]) error
+
+type subscriberOptions struct {
+	pollTimeout  time.Duration
+	retryBackoff time.Duration
+	retry        *retrier
+	concurrency  int
+	maxInFlight  int
+	keyOrdering  bool
+	middlewares  []Middleware
+	envFilter    bool
+}
+
+type SubscriberOption func(*subscriberOptions)
+
+// WithPollTimeout sets how long a single poll blocks waiting for messages.
+func WithPollTimeout(timeout time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.pollTimeout = timeout
+	}
+}
+
+// WithRetryBackoff sets the pause before a failed message is redelivered
+// when no RetryPolicy is configured.
+func WithRetryBackoff(backoff time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.retryBackoff = backoff
+	}
+}
+
+// WithRetryPolicy routes failed messages through retry topics and an optional
+// dead-letter topic, published with producer.
+func WithRetryPolicy(producer *Producer, policy RetryPolicy) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.retry = &retrier{producer: producer, policy: policy}
+	}
+}
+
+// Subscriber consumes a topic with consumers borrowed from the manager's
+// pools, decodes every message into T and passes it to a Handler.
+//
+// Offsets are committed only after the handler succeeds. Without a
+// RetryPolicy a failed message is redelivered after the retry backoff by
+// seeking back to it, and messages that cannot be decoded are logged and
+// skipped. With a RetryPolicy failed messages are forwarded to the retry or
+// dead-letter topics and their offsets are committed once forwarded. See
+// WithConcurrency for handling partitions or keys in parallel.
+type Subscriber[T any] struct {
+	cm      *ConsumerManager
+	topic   string
+	handler Handler[T]
+	chain   MessageHandler
+	opts    *subscriberOptions
+	logger  logging.Logger
+}
+
+// NewSubscriber creates a subscriber for topic. Group ID, brokers and logger
+// are taken from the consumer manager's configuration.
+func NewSubscriber[T any](cm *ConsumerManager, topic string, handler Handler[T], opts ...SubscriberOption) *Subscriber[T] {
+	options := &subscriberOptions{
+		pollTimeout:  defaultPollTimeout,
+		retryBackoff: defaultRetryBackoff,
+		maxInFlight:  defaultMaxInFlight,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.retry != nil {
+		options.retry.topic = topic
+	}
+
+	s := &Subscriber[T]{
+		cm:      cm,
+		topic:   topic,
+		handler: handler,
+		opts:    options,
+		logger:  cm.logger,
+	}
+	middlewares := options.middlewares
+	if options.envFilter {
+		middlewares = append([]Middleware{EnvironmentFilter(cm.cfg.Environment, cm.logger)}, middlewares...)
+	}
+	s.chain = Chain(s.decodeAndHandle, middlewares...)
+	return s
+}
+
+// Run polls the topic, and its retry topics when a RetryPolicy is set, until
+// ctx is cancelled. The message being handled when ctx is cancelled is
+// allowed to finish before Run returns. Run only returns an error when no
+// consumer can be borrowed, the retry topics cannot be created or a consumer
+// hits a fatal error.
+func (s *Subscriber[T]) Run(ctx context.Context) error {
+	if s.opts.retry == nil {
+		return s.consume(ctx, s.topic)
+	}
+
+	if err := s.opts.retry.ensureTopics(); err != nil {
+		return fmt.Errorf("failed to ensure retry topics: %w", err)
+	}
+	topics := append([]string{s.topic}, s.opts.retry.retryTopics()...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errCh := make(chan error, len(topics))
+	for _, topic := range topics {
+		go func() {
+			errCh <- s.consume(ctx, topic)
+		}()
+	}
+
+	var firstErr error
+	for range topics {
+		if err := <-errCh; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
+
+// consume polls topic until ctx is cancelled. The consumer is given back to
+// its pool only when it can be reused as is: with no partition paused and
+// positioned at its first uncommitted message. Otherwise it is closed.
+func (s *Subscriber[T]) consume(ctx context.Context, topic string) error {
+	pool := s.cm.getPool(topic, false)
+	consumer, release, err := pool.BorrowReader()
+	if err != nil {
+		return fmt.Errorf("failed to borrow consumer for topic %s: %w", topic, err)
+	}
+
+	delayed := delayedPartitions{}
+	reusable := true
+	defer func() {
+		if err := delayed.resumeAll(consumer); err != nil {
+			s.logger.Errorf("Failed to resume delayed partitions on topic %s, closing the consumer: %v", topic, err)
+			reusable = false
+		}
+		if reusable {
+			release()
+		} else {
+			pool.discard(consumer)
+		}
+	}()
+
+	if s.opts.concurrency > 1 {
+		reusable, err = s.consumeConcurrently(ctx, pool, consumer, topic, delayed)
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		msg, err := s.poll(consumer, topic, delayed)
+		if err != nil {
+			reusable = false
+			return err
+		}
+		if msg != nil {
+			s.process(ctx, consumer, msg)
+		}
+	}
+}
+
+// poll returns the next message that is due for handling, or nil when none
+// is. Messages of retry topics whose backoff has not elapsed yet are delayed.
+// Only fatal consumer errors are returned.
+func (s *Subscriber[T]) poll(consumer Reader, topic string, delayed delayedPartitions) (*ckafka.Message, error) {
+	if err := delayed.resumeDue(consumer); err != nil {
+		s.logger.Errorf("Failed to resume delayed partitions on topic %s: %v", topic, err)
+	}
+
+	msg, err := readMessage(consumer, s.opts.pollTimeout)
+	if err != nil {
+		if isFatal(err) {
+			s.logger.Errorf("Fatal consumer error on topic %s: %v", topic, err)
+			return nil, err
+		}
+		s.logger.Errorf("Consumer error on topic %s: %v", topic, err)
+		return nil, nil
+	}
+	if msg == nil {
+		return nil, nil
+	}
+	if due, ok := retryAt(msg); ok && topic != s.topic && time.Now().Before(due) {
+		if err := delayed.delay(consumer, msg, due); err != nil {
+			s.logger.Errorf("Failed to delay message %s: %v", msg.TopicPartition, err)
+		}
+		return nil, nil
+	}
+	return msg, nil
+}
+
+func (s *Subscriber[T]) process(ctx context.Context, consumer Reader, raw *ckafka.Message) {
+	err := s.handle(ctx, raw)
+	if err == nil {
+		s.commit(consumer, raw)
+		return
+	}
+
+	var decodeErr *decodeError
+	retryable := !errors.As(err, &decodeErr)
+	s.logger.Errorf("Failed to handle message %s: %v", raw.TopicPartition, err)
+
+	if s.opts.retry == nil {
+		if retryable {
+			s.redeliver(ctx, consumer, raw)
+			return
+		}
+		s.commit(consumer, raw)
+		return
+	}
+
+	if !s.forward(ctx, raw, err, retryable) {
+		s.redeliver(ctx, consumer, raw)
+		return
+	}
+	s.commit(consumer, raw)
+}
+
+// forward hands a failed message to the retry policy and reports whether it
+// was forwarded or deliberately dropped.
+func (s *Subscriber[T]) forward(ctx context.Context, raw *ckafka.Message, cause error, retryable bool) bool {
+	topic, err := s.opts.retry.forward(ctx, raw, cause, retryable)
+	if err != nil {
+		s.logger.Errorf("Failed to forward message %s: %v", raw.TopicPartition, err)
+		return false
+	}
+	if topic == "" {
+		s.logger.Errorf("Dropping message %s after %d attempts", raw.TopicPartition, retryAttempt(raw)+1)
+	} else {
+		s.logger.Infof("Forwarded message %s to %s", raw.TopicPartition, topic)
+	}
+	return true
+}
+
+// handle runs raw through the middlewares before decoding it and passing it
+// to the handler.
+func (s *Subscriber[T]) handle(ctx context.Context, raw *ckafka.Message) error {
+	return s.chain(ctx, raw)
+}
+
+func (s *Subscriber[T]) decodeAndHandle(ctx context.Context, raw *ckafka.Message) error {
+	value, err := decodeValue[T](s.cm.cfg, raw)
+	if err != nil {
+		return &decodeError{err: err}
+	}
+	return s.handler(ctx, &Message[T]{Value: value, Raw: raw})
+}
+
+// redeliver rewinds the consumer to raw so it is polled again after the
+// retry backoff.
+func (s *Subscriber[T]) redeliver(ctx context.Context, consumer Reader, raw *ckafka.Message) {
+	if err := consumer.Seek(raw.TopicPartition, 0); err != nil {
+		s.logger.Errorf("Failed to seek back to message %s: %v", raw.TopicPartition, err)
+	}
+	sleep(ctx, s.opts.retryBackoff)
+}
+
+func (s *Subscriber[T]) commit(consumer Reader, raw *ckafka.Message) {
+	if _, err := consumer.CommitMessage(raw); err != nil {
+		s.logger.Errorf("Failed to commit message %s: %v", raw.TopicPartition, err)
+	}
+}
+
+// decodeError marks a message whose value could not be decoded. Such
+// messages are never retried.
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return "failed to decode message: " + e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+// decodeValue decodes the message value into T with the deserializer cfg
+// picks for it. []byte and string targets receive the raw value unchanged.
+func decodeValue[T any](cfg *Config, msg *ckafka.Message) (T, error) {
+	var value T
+	switch v := any(&value).(type) {
+	case *[]byte:
+		*v = msg.Value
+		return value, nil
+	case *string:
+		*v = string(msg.Value)
+		return value, nil
+	}
+
+	deserializer, err := cfg.deserializer(msg)
+	if err != nil {
+		return value, err
+	}
+
+	// Pointer targets such as protobuf messages are allocated and decoded
+	// into directly.
+	target := any(&value)
+	if t := reflect.TypeFor[T](); t.Kind() == reflect.Pointer {
+		value = reflect.New(t.Elem()).Interface().(T)
+		target = value
+	}
+	topic := ""
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+	if err := deserializer.DeserializeInto(topic, msg.Value, target); err != nil {
+		return value, fmt.Errorf("failed to deserialize message value: %w", err)
+	}
+	return value, nil
+}
+
+// sleep pauses for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
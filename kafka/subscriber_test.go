@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+type testEvent struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecodeValue_JSON(t *testing.T) {
+	msg := &ckafka.Message{
+		Value:   []byte(`{"id":"1","name":"created"}`),
+		Headers: []ckafka.Header{{Key: HeaderContentType, Value: []byte("application/json; charset=utf-8")}},
+	}
+
+	value, err := decodeValue[testEvent](newConfig(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value.ID != "1" || value.Name != "created" {
+		t.Errorf("unexpected value: %+v", value)
+	}
+}
+
+func TestDecodeValue_MissingContentType(t *testing.T) {
+	msg := &ckafka.Message{Value: []byte(`{"id":"2"}`)}
+
+	value, err := decodeValue[testEvent](newConfig(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value.ID != "2" {
+		t.Errorf("expected ID to be '2', got '%s'", value.ID)
+	}
+}
+
+func TestDecodeValue_UnsupportedContentType(t *testing.T) {
+	msg := &ckafka.Message{
+		Value:   []byte(`<event/>`),
+		Headers: []ckafka.Header{{Key: HeaderContentType, Value: []byte("application/xml")}},
+	}
+
+	if _, err := decodeValue[testEvent](newConfig(), msg); err == nil {
+		t.Fatal("expected error for unsupported content type")
+	}
+}
+
+func TestDecodeValue_Raw(t *testing.T) {
+	msg := &ckafka.Message{
+		Value:   []byte("plain"),
+		Headers: []ckafka.Header{{Key: HeaderContentType, Value: []byte("text/plain")}},
+	}
+
+	b, err := decodeValue[[]byte](newConfig(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(b) != "plain" {
+		t.Errorf("expected 'plain', got '%s'", b)
+	}
+
+	s, err := decodeValue[string](newConfig(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s != "plain" {
+		t.Errorf("expected 'plain', got '%s'", s)
+	}
+}
+
+// fatalReader is a Reader whose every read fails with a fatal error.
+type fatalReader struct {
+	Reader
+	closed bool
+}
+
+func (r *fatalReader) ReadMessage(time.Duration) (*ckafka.Message, error) {
+	return nil, ckafka.NewError(ckafka.ErrFatal, "fatal", true)
+}
+
+func (r *fatalReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func newFatalManager() (*ConsumerManager, *fatalReader) {
+	reader := &fatalReader{}
+	return NewConsumerManager(WithGroupID("g"), WithReaderFactory(func(string, string, bool) (Reader, error) {
+		return reader, nil
+	})), reader
+}
+
+func TestSubscriber_DiscardsConsumerOnFatalError(t *testing.T) {
+	cm, reader := newFatalManager()
+	sub := NewSubscriber(cm, "orders", func(ctx context.Context, msg *Message[[]byte]) error {
+		return nil
+	})
+
+	var kafkaErr ckafka.Error
+	if err := sub.Run(context.Background()); !errors.As(err, &kafkaErr) || !kafkaErr.IsFatal() {
+		t.Fatalf("expected the fatal error, got %v", err)
+	}
+	if !reader.closed {
+		t.Error("expected the consumer to be closed instead of returned to the pool")
+	}
+}
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Sender is the publishing surface of Producer. Code that only sends
+// messages can depend on it and be tested with kafkatest.Producer.
+type Sender interface {
+	Send(topic string, value []byte, opts ...MessageOption) error
+	SendSync(ctx context.Context, topic string, value []byte, opts ...MessageOption) (ckafka.TopicPartition, error)
+}
+
+// Reader is the consuming surface of the consumers borrowed from a
+// ConsumerPool with BorrowReader. It is implemented by *ckafka.Consumer and
+// by kafkatest.Consumer.
+type Reader interface {
+	ReadMessage(timeout time.Duration) (*ckafka.Message, error)
+	CommitMessage(msg *ckafka.Message) ([]ckafka.TopicPartition, error)
+	CommitOffsets(offsets []ckafka.TopicPartition) ([]ckafka.TopicPartition, error)
+	Seek(partition ckafka.TopicPartition, ignoredTimeoutMs int) error
+	SeekPartitions(partitions []ckafka.TopicPartition) ([]ckafka.TopicPartition, error)
+	Pause(partitions []ckafka.TopicPartition) error
+	Resume(partitions []ckafka.TopicPartition) error
+	Close() error
+}
+
+// ReaderFactory creates the consumers of a ConsumerPool: a Reader of topic
+// for groupID, committing its offsets automatically when autoCommit is set.
+type ReaderFactory func(groupID string, topic string, autoCommit bool) (Reader, error)
+
+// WithReaderFactory makes consumer pools create their consumers with factory
+// instead of connecting to the brokers, e.g. to run a Subscriber against
+// kafkatest.Broker. Rebalance callbacks are not called for such consumers,
+// and ConsumerPool.Borrow fails for them; use BorrowReader.
+func WithReaderFactory(factory ReaderFactory) Option {
+	return func(o *configOptions) {
+		o.readerFactory = factory
+	}
+}
+
+// readMessage reads the next message from reader, returning nil and no error
+// when none arrived within timeout.
+func readMessage(reader Reader, timeout time.Duration) (*ckafka.Message, error) {
+	msg, err := reader.ReadMessage(timeout)
+	var kafkaErr ckafka.Error
+	if errors.As(err, &kafkaErr) && kafkaErr.Code() == ckafka.ErrTimedOut {
+		return nil, nil
+	}
+	return msg, err
+}
+
+// isFatal reports whether err is a fatal consumer error, after which the
+// consumer is unusable.
+func isFatal(err error) bool {
+	var kafkaErr ckafka.Error
+	return errors.As(err, &kafkaErr) && kafkaErr.IsFatal()
+}
+
+var (
+	_ Sender = (*Producer)(nil)
+	_ Reader = (*ckafka.Consumer)(nil)
+)
@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const defaultTransactionTimeout = 30 * time.Second
+
+// ErrNotTransactional is returned by the transaction methods of producers
+// created without WithTransactionalID.
+var ErrNotTransactional = errors.New("kafka producer is not transactional")
+
+// BeginTransaction starts a transaction. Every message sent until the
+// transaction is committed or aborted becomes part of it.
+func (p *Producer) BeginTransaction() error {
+	if p.cfg.TransactionalID == "" {
+		return ErrNotTransactional
+	}
+	return p.producer.BeginTransaction()
+}
+
+// CommitTransaction flushes and commits the current transaction, retrying
+// retriable errors until ctx is done. When the broker requires the
+// transaction to be aborted, it is aborted and the commit error returned.
+func (p *Producer) CommitTransaction(ctx context.Context) error {
+	if p.cfg.TransactionalID == "" {
+		return ErrNotTransactional
+	}
+
+	for {
+		err := p.producer.CommitTransaction(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var kerr ckafka.Error
+		if !errors.As(err, &kerr) {
+			return err
+		}
+		if kerr.TxnRequiresAbort() {
+			if abortErr := p.AbortTransaction(ctx); abortErr != nil {
+				p.logger.Errorf("Failed to abort kafka transaction: %v", abortErr)
+			}
+			return err
+		}
+		if !kerr.IsRetriable() || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// AbortTransaction aborts the current transaction, purging its outstanding
+// messages. Retriable errors are retried until ctx is done.
+func (p *Producer) AbortTransaction(ctx context.Context) error {
+	if p.cfg.TransactionalID == "" {
+		return ErrNotTransactional
+	}
+
+	for {
+		err := p.producer.AbortTransaction(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var kerr ckafka.Error
+		if !errors.As(err, &kerr) || !kerr.IsRetriable() || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// RunInTransaction runs fn inside a transaction. The transaction is committed
+// when fn succeeds and aborted when it fails.
+func (p *Producer) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := p.BeginTransaction(); err != nil {
+		return err
+	}
+
+	if err := fn(ctx); err != nil {
+		if abortErr := p.AbortTransaction(ctx); abortErr != nil {
+			p.logger.Errorf("Failed to abort kafka transaction: %v", abortErr)
+		}
+		return err
+	}
+
+	return p.CommitTransaction(ctx)
+}
+
+// ConsumeTransformProduce processes msgs read by consumer within a single
+// transaction: fn sends its output through p, then the consumer offsets past
+// msgs are added to the transaction so output and input offsets are committed
+// atomically. If anything fails the transaction is aborted and consumer is
+// rewound to msgs so they are consumed again.
+//
+// consumer must not auto-commit its offsets, see
+// ConsumerManager.GetManualCommitPool, and must expose its consumer group
+// metadata like *ckafka.Consumer does.
+func (p *Producer) ConsumeTransformProduce(ctx context.Context, consumer Reader, msgs []*ckafka.Message, fn func(ctx context.Context) error) error {
+	group, ok := consumer.(groupMetadataReader)
+	if !ok {
+		return fmt.Errorf("consumer %T has no consumer group metadata", consumer)
+	}
+
+	err := p.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+
+		metadata, err := group.GetConsumerGroupMetadata()
+		if err != nil {
+			return fmt.Errorf("failed to get consumer group metadata: %w", err)
+		}
+		return p.producer.SendOffsetsToTransaction(ctx, nextOffsets(msgs), metadata)
+	})
+	if err != nil {
+		if _, seekErr := consumer.SeekPartitions(firstOffsets(msgs)); seekErr != nil {
+			p.logger.Errorf("Failed to rewind consumer after aborted transaction: %v", seekErr)
+		}
+	}
+	return err
+}
+
+// groupMetadataReader is implemented by readers that can take part in a
+// transaction, such as *ckafka.Consumer.
+type groupMetadataReader interface {
+	GetConsumerGroupMetadata() (*ckafka.ConsumerGroupMetadata, error)
+}
+
+// nextOffsets returns, per partition, the offset following the last of msgs,
+// which is the offset to commit once msgs are processed.
+func nextOffsets(msgs []*ckafka.Message) []ckafka.TopicPartition {
+	ranges := offsetRanges(msgs)
+	offsets := make([]ckafka.TopicPartition, len(ranges))
+	for i, r := range ranges {
+		offsets[i] = ckafka.TopicPartition{Topic: r.topic, Partition: r.partition, Offset: r.last + 1}
+	}
+	return offsets
+}
+
+// firstOffsets returns, per partition, the offset of the first of msgs.
+func firstOffsets(msgs []*ckafka.Message) []ckafka.TopicPartition {
+	ranges := offsetRanges(msgs)
+	offsets := make([]ckafka.TopicPartition, len(ranges))
+	for i, r := range ranges {
+		offsets[i] = ckafka.TopicPartition{Topic: r.topic, Partition: r.partition, Offset: r.first}
+	}
+	return offsets
+}
+
+// offsetRange is the lowest and highest offset seen on a partition.
+type offsetRange struct {
+	topic     *string
+	partition int32
+	first     ckafka.Offset
+	last      ckafka.Offset
+}
+
+// offsetRanges groups msgs by partition, in order of first appearance.
+func offsetRanges(msgs []*ckafka.Message) []offsetRange {
+	index := make(map[string]int)
+	ranges := make([]offsetRange, 0)
+	for _, msg := range msgs {
+		tp := msg.TopicPartition
+		key := partitionKey(tp)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(ranges)
+			ranges = append(ranges, offsetRange{topic: tp.Topic, partition: tp.Partition, first: tp.Offset, last: tp.Offset})
+			continue
+		}
+		ranges[i].first = min(ranges[i].first, tp.Offset)
+		ranges[i].last = max(ranges[i].last, tp.Offset)
+	}
+	return ranges
+}
@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func testMessage(topic string, partition int32, offset ckafka.Offset) *ckafka.Message {
+	return &ckafka.Message{TopicPartition: ckafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}}
+}
+
+func TestTransactionOffsets(t *testing.T) {
+	msgs := []*ckafka.Message{
+		testMessage("orders", 0, 5),
+		testMessage("orders", 1, 9),
+		testMessage("orders", 0, 7),
+		testMessage("orders", 0, 6),
+	}
+
+	next := nextOffsets(msgs)
+	if len(next) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(next))
+	}
+	if next[0].Partition != 0 || next[0].Offset != 8 {
+		t.Errorf("expected orders[0]@8, got %v", next[0])
+	}
+	if next[1].Partition != 1 || next[1].Offset != 10 {
+		t.Errorf("expected orders[1]@10, got %v", next[1])
+	}
+
+	first := firstOffsets(msgs)
+	if first[0].Offset != 5 || first[1].Offset != 9 {
+		t.Errorf("expected first offsets 5 and 9, got %v", first)
+	}
+}
+
+func TestTransactionMethodsRequireTransactionalID(t *testing.T) {
+	p := &Producer{cfg: newConfig()}
+
+	if err := p.BeginTransaction(); err != ErrNotTransactional {
+		t.Errorf("expected ErrNotTransactional, got %v", err)
+	}
+	if err := p.CommitTransaction(context.Background()); err != ErrNotTransactional {
+		t.Errorf("expected ErrNotTransactional, got %v", err)
+	}
+	if err := p.AbortTransaction(context.Background()); err != ErrNotTransactional {
+		t.Errorf("expected ErrNotTransactional, got %v", err)
+	}
+}
+
+func TestWithTransactionalIDImpliesIdempotence(t *testing.T) {
+	cfg := newConfig(WithTransactionalID("orders-tx"))
+	if !cfg.Idempotence {
+		t.Error("expected transactional config to enable idempotence")
+	}
+}
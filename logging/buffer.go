@@ -0,0 +1,297 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.uber.org/zap/zapcore"
+)
+
+// DropPolicy decides which entries are lost when the KafkaCore buffer is
+// full.
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered entry to make room.
+	DropOldest DropPolicy = iota
+	// DropDebugFirst discards the oldest buffered debug entry, or the new
+	// entry when it is a debug entry itself, and falls back to DropOldest.
+	DropDebugFirst
+	// Block waits up to the block timeout for room and then discards the new
+	// entry.
+	Block
+)
+
+// CoreStats are the counters of a KafkaCore.
+type CoreStats struct {
+	// Buffered is the number of entries waiting for the producer.
+	Buffered int
+	// Dropped counts the entries discarded because the buffer was full.
+	Dropped uint64
+	// Failed counts the entries the producer refused or failed to deliver,
+	// which were written to the fallback sink instead.
+	Failed uint64
+}
+
+type bufferedEntry struct {
+	level zapcore.Level
+	msg   *kafka.Message
+}
+
+// kafkaSink buffers the entries of a KafkaCore and its clones, and hands
+// them to the producer from a single goroutine.
+type kafkaSink struct {
+	producer     *kafka.Producer
+	fallback     zapcore.WriteSyncer
+	errorOutput  zapcore.WriteSyncer
+	errorHook    func(err error, entry []byte)
+	size         int
+	policy       DropPolicy
+	blockTimeout time.Duration
+	flushTimeout time.Duration
+
+	mu      sync.Mutex
+	entries []*bufferedEntry
+	// busy is set while the drain loop is producing an entry.
+	busy   bool
+	closed bool
+	// changed is closed and replaced whenever entries or busy change.
+	changed chan struct{}
+	done    chan struct{}
+	// eventsDone is closed once the producer's event channel is closed.
+	eventsDone chan struct{}
+
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func newKafkaSink(producer *kafka.Producer, options *coreOptions) *kafkaSink {
+	size := options.bufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	s := &kafkaSink{
+		producer:     producer,
+		fallback:     zapcore.Lock(options.fallback),
+		errorOutput:  zapcore.Lock(options.errorOutput),
+		errorHook:    options.errorHook,
+		size:         size,
+		policy:       options.dropPolicy,
+		blockTimeout: options.blockTimeout,
+		flushTimeout: options.flushTimeout,
+		changed:      make(chan struct{}),
+		done:         make(chan struct{}),
+		eventsDone:   make(chan struct{}),
+	}
+	go s.drain()
+	go s.handleEvents()
+	return s
+}
+
+// notify wakes the goroutines waiting on changed. s.mu must be held.
+func (s *kafkaSink) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *kafkaSink) enqueue(entry *bufferedEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		s.toFallback(entry)
+		return
+	}
+	if len(s.entries) >= s.size && !s.makeRoom(entry) {
+		s.dropped.Add(1)
+		return
+	}
+	s.entries = append(s.entries, entry)
+	s.notify()
+}
+
+// makeRoom frees a slot for entry according to the drop policy. It reports
+// false when entry itself is to be dropped. s.mu must be held.
+func (s *kafkaSink) makeRoom(entry *bufferedEntry) bool {
+	switch s.policy {
+	case DropDebugFirst:
+		if entry.level <= zapcore.DebugLevel {
+			return false
+		}
+		for i, buffered := range s.entries {
+			if buffered.level <= zapcore.DebugLevel {
+				s.entries = append(s.entries[:i], s.entries[i+1:]...)
+				s.dropped.Add(1)
+				return true
+			}
+		}
+	case Block:
+		timer := time.NewTimer(s.blockTimeout)
+		defer timer.Stop()
+		for len(s.entries) >= s.size && !s.closed {
+			changed := s.changed
+			s.mu.Unlock()
+			select {
+			case <-changed:
+				s.mu.Lock()
+			case <-timer.C:
+				s.mu.Lock()
+				return len(s.entries) < s.size
+			}
+		}
+		return len(s.entries) < s.size
+	}
+
+	s.entries = s.entries[1:]
+	s.dropped.Add(1)
+	return true
+}
+
+// drain hands the buffered entries to the producer until the sink is closed
+// and empty.
+func (s *kafkaSink) drain() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.entries) == 0 {
+			if s.closed {
+				s.mu.Unlock()
+				return
+			}
+			changed := s.changed
+			s.mu.Unlock()
+			<-changed
+			s.mu.Lock()
+		}
+		entry := s.entries[0]
+		s.entries = s.entries[1:]
+		s.busy = true
+		s.notify()
+		s.mu.Unlock()
+
+		if err := s.producer.Produce(entry.msg, nil); err != nil {
+			s.toFallback(entry)
+		}
+
+		s.mu.Lock()
+		s.busy = false
+		s.notify()
+		s.mu.Unlock()
+	}
+}
+
+// handleEvents reads the producer's delivery reports and errors until the
+// producer is closed. Entries that failed delivery go to the fallback sink.
+func (s *kafkaSink) handleEvents() {
+	defer close(s.eventsDone)
+	for ev := range s.producer.Events() {
+		switch e := ev.(type) {
+		case *kafka.Message:
+			if err := e.TopicPartition.Error; err != nil {
+				s.reportError(fmt.Errorf("failed to deliver log entry: %w", err), e.Value)
+				s.failed.Add(1)
+				_, _ = s.fallback.Write(append(e.Value, '\n'))
+			}
+		case kafka.Error:
+			s.reportError(e, nil)
+		}
+	}
+}
+
+// reportError writes err to the error output and passes it to the error
+// hook. entry is the undelivered entry, if any.
+func (s *kafkaSink) reportError(err error, entry []byte) {
+	_, _ = fmt.Fprintf(s.errorOutput, "%s kafka log core error: %v\n", time.Now().Format(time.RFC3339Nano), err)
+	_ = s.errorOutput.Sync()
+	if s.errorHook != nil {
+		s.errorHook(err, entry)
+	}
+}
+
+// toFallback writes an entry the producer did not take to the fallback
+// sink.
+func (s *kafkaSink) toFallback(entry *bufferedEntry) {
+	s.failed.Add(1)
+	_, _ = s.fallback.Write(append(entry.msg.Value, '\n'))
+}
+
+// waitDrained waits up to timeout for every buffered entry to be handed to
+// the producer. It reports whether the buffer was drained.
+func (s *kafkaSink) waitDrained(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.entries) > 0 || s.busy {
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+			s.mu.Lock()
+		case <-timer.C:
+			s.mu.Lock()
+			return len(s.entries) == 0 && !s.busy
+		}
+	}
+	return true
+}
+
+// sync waits for the buffered entries to be delivered. It fails when some
+// are still outstanding after the flush timeout.
+func (s *kafkaSink) sync() error {
+	start := time.Now()
+	if !s.waitDrained(s.flushTimeout) {
+		_ = s.fallback.Sync()
+		return fmt.Errorf("kafka log core: %d entries still buffered after %s", s.stats().Buffered, s.flushTimeout)
+	}
+	remaining := s.producer.Flush(int((s.flushTimeout - time.Since(start)).Milliseconds()))
+	_ = s.fallback.Sync()
+	if remaining > 0 {
+		return fmt.Errorf("kafka log core: %d entries still outstanding after %s", remaining, s.flushTimeout)
+	}
+	return nil
+}
+
+// close drains the buffer and closes the producer. Entries still
+// outstanding after the flush timeout are purged and written to the
+// fallback sink, and entries written afterwards go there too.
+func (s *kafkaSink) close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.notify()
+	s.mu.Unlock()
+
+	<-s.done
+	var err error
+	if remaining := s.producer.Flush(int(s.flushTimeout.Milliseconds())); remaining > 0 {
+		err = fmt.Errorf("kafka log core: %d entries not delivered before close", remaining)
+		// Purged entries are reported as failed deliveries, which moves
+		// them to the fallback sink.
+		_ = s.producer.Purge(kafka.PurgeQueue | kafka.PurgeInFlight)
+		s.producer.Flush(int(defaultPurgeTimeout.Milliseconds()))
+	}
+	s.producer.Close()
+	<-s.eventsDone
+	_ = s.fallback.Sync()
+	return err
+}
+
+func (s *kafkaSink) stats() CoreStats {
+	s.mu.Lock()
+	buffered := len(s.entries)
+	s.mu.Unlock()
+	return CoreStats{
+		Buffered: buffered,
+		Dropped:  s.dropped.Load(),
+		Failed:   s.failed.Load(),
+	}
+}
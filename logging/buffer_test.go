@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newTestSink returns a sink without a drain loop, so entries stay buffered.
+func newTestSink(size int, policy DropPolicy) *kafkaSink {
+	return &kafkaSink{size: size, policy: policy, changed: make(chan struct{})}
+}
+
+func testEntry(level zapcore.Level, value string) *bufferedEntry {
+	return &bufferedEntry{level: level, msg: &kafka.Message{Value: []byte(value)}}
+}
+
+func bufferedValues(s *kafkaSink) string {
+	values := make([]string, len(s.entries))
+	for i, entry := range s.entries {
+		values[i] = string(entry.msg.Value)
+	}
+	return strings.Join(values, ",")
+}
+
+func TestKafkaSink_DropPolicies(t *testing.T) {
+	oldest := newTestSink(2, DropOldest)
+	for _, v := range []string{"a", "b", "c"} {
+		oldest.enqueue(testEntry(zapcore.InfoLevel, v))
+	}
+	if got := bufferedValues(oldest); got != "b,c" {
+		t.Errorf("DropOldest: expected b,c, got %s", got)
+	}
+
+	debugFirst := newTestSink(2, DropDebugFirst)
+	debugFirst.enqueue(testEntry(zapcore.InfoLevel, "info"))
+	debugFirst.enqueue(testEntry(zapcore.DebugLevel, "debug"))
+	debugFirst.enqueue(testEntry(zapcore.DebugLevel, "new-debug"))
+	debugFirst.enqueue(testEntry(zapcore.ErrorLevel, "error"))
+	if got := bufferedValues(debugFirst); got != "info,error" {
+		t.Errorf("DropDebugFirst: expected info,error, got %s", got)
+	}
+
+	block := newTestSink(1, Block)
+	block.enqueue(testEntry(zapcore.InfoLevel, "first"))
+	block.enqueue(testEntry(zapcore.InfoLevel, "second"))
+	if got := bufferedValues(block); got != "first" {
+		t.Errorf("Block: expected first, got %s", got)
+	}
+
+	for sink, want := range map[*kafkaSink]uint64{oldest: 1, debugFirst: 2, block: 1} {
+		if dropped := sink.stats().Dropped; dropped != want {
+			t.Errorf("expected %d dropped entries, got %d", want, dropped)
+		}
+	}
+}
+
+func TestKafkaCore_Fallback(t *testing.T) {
+	var fallback, errOutput bytes.Buffer
+	var hookErrs []error
+	core, err := NewKafkaLogCore([]string{"127.0.0.1:1"}, "app-logs", zapcore.InfoLevel, "billing",
+		WithKafkaConfig(kafka.ConfigMap{"queue.buffering.max.messages": 1, "message.timeout.ms": 100}),
+		WithFallback(zapcore.AddSync(&fallback)),
+		WithErrorOutput(zapcore.AddSync(&errOutput)),
+		WithErrorHook(func(err error, entry []byte) {
+			if entry != nil {
+				hookErrs = append(hookErrs, err)
+			}
+		}),
+		WithFlushTimeout(5*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logger := zap.New(core)
+	for i := 0; i < 3; i++ {
+		logger.Info("payment failed")
+	}
+	// The entry taken by the producer times out, the others do not fit in
+	// its queue.
+	if err := core.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := core.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := core.Stats()
+	if stats.Failed != 3 || stats.Buffered != 0 {
+		t.Fatalf("expected every entry to fail, got %+v", stats)
+	}
+	if lines := strings.Count(fallback.String(), "payment failed"); lines != 3 {
+		t.Fatalf("expected 3 entries in the fallback sink, got %q", fallback.String())
+	}
+	if len(hookErrs) != 1 || !strings.Contains(errOutput.String(), "failed to deliver log entry") {
+		t.Fatalf("expected the delivery failure to be reported, got %v and %q", hookErrs, errOutput.String())
+	}
+}
+
+func TestKafkaCore_SyncOutstanding(t *testing.T) {
+	var fallback bytes.Buffer
+	core, err := NewKafkaLogCore([]string{"127.0.0.1:1"}, "app-logs", zapcore.InfoLevel, "billing",
+		WithFallback(zapcore.AddSync(&fallback)),
+		WithErrorOutput(zapcore.AddSync(&bytes.Buffer{})),
+		WithFlushTimeout(100*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	zap.New(core).Info("payment failed")
+	if err := core.Sync(); err == nil {
+		t.Fatal("expected an error while the entry is outstanding")
+	}
+	if err := core.Close(); err == nil {
+		t.Fatal("expected an error for the undelivered entry")
+	}
+	if !strings.Contains(fallback.String(), "payment failed") {
+		t.Fatalf("expected the purged entry in the fallback sink, got %q", fallback.String())
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	current, _ := os.ReadFile(path)
+	backup, _ := os.ReadFile(path + ".1")
+	if string(current) != "third\n" || string(backup) != "second\n" {
+		t.Fatalf("unexpected files %q and %q", current, backup)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("expected a single backup, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/liberty-group-tech/wello-go-common/helper"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	requestIDPrefix = "req"
+	contextFieldKey = "context"
+)
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// WithRequestID returns a copy of ctx carrying requestID. An empty requestID
+// is replaced with a newly generated one.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		requestID = helper.GenerateID(requestIDPrefix)
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
+
+// NewContext returns a copy of ctx carrying logger, for FromContext.
+func NewContext(ctx context.Context, logger *ZapLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+var nopLogger = newZapLogger(zap.NewNop(), nil)
+
+// FromContext returns the logger attached to ctx with NewContext, or a
+// no-op logger, adding the request and trace IDs of ctx to every entry.
+func FromContext(ctx context.Context) *ZapLogger {
+	logger, ok := ctx.Value(loggerKey{}).(*ZapLogger)
+	if !ok {
+		logger = nopLogger
+	}
+	return logger.WithContext(ctx)
+}
+
+// Context returns a field that a ContextCore replaces with the request ID,
+// trace ID and span ID carried by ctx. Other cores ignore it.
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: contextFieldKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// contextFields returns the LogBase fields filled from ctx.
+func contextFields(ctx context.Context) []zapcore.Field {
+	var fields []zapcore.Field
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		fields = append(fields, zap.String("requestId", requestID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields = append(fields,
+			zap.String("traceId", span.TraceID().String()),
+			zap.String("spanId", span.SpanID().String()),
+		)
+	}
+	return fields
+}
+
+// expandContext replaces the fields built by Context with the fields they
+// carry. It returns fields unchanged when there are none.
+func expandContext(fields []zapcore.Field) []zapcore.Field {
+	found := false
+	for _, f := range fields {
+		if isContextField(f) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fields
+	}
+
+	expanded := make([]zapcore.Field, 0, len(fields)+2)
+	for _, f := range fields {
+		if isContextField(f) {
+			expanded = append(expanded, contextFields(f.Interface.(context.Context))...)
+			continue
+		}
+		expanded = append(expanded, f)
+	}
+	return expanded
+}
+
+func isContextField(f zapcore.Field) bool {
+	if f.Type != zapcore.SkipType || f.Key != contextFieldKey {
+		return false
+	}
+	_, ok := f.Interface.(context.Context)
+	return ok
+}
+
+// ContextCore wraps a core so that entries logged with a Context field
+// carry the requestId, traceId and spanId fields of LogBase.
+type ContextCore struct {
+	zapcore.Core
+}
+
+// NewContextCore wraps core, see ContextCore. logging.New wraps its cores
+// already.
+//
+// core must be a single core, not a Tee: the wrapper writes every entry that
+// core enables to all of core, so the cores of a Tee would lose their own
+// levels. Wrap them one by one with NewContextTee instead.
+func NewContextCore(core zapcore.Core) zapcore.Core {
+	return &ContextCore{Core: core}
+}
+
+// NewContextTee wraps every core in a ContextCore and tees them, so each
+// keeps filtering entries at its own level.
+func NewContextTee(cores ...zapcore.Core) zapcore.Core {
+	wrapped := make([]zapcore.Core, len(cores))
+	for i, core := range cores {
+		wrapped[i] = NewContextCore(core)
+	}
+	return zapcore.NewTee(wrapped...)
+}
+
+func (c *ContextCore) With(fields []zapcore.Field) zapcore.Core {
+	return &ContextCore{Core: c.Core.With(expandContext(fields))}
+}
+
+func (c *ContextCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *ContextCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, expandContext(fields))
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	if id, ok := RequestIDFromContext(ctx); !ok || id != "req-1" {
+		t.Errorf("expected req-1, got %q, %v", id, ok)
+	}
+
+	ctx = WithRequestID(context.Background(), "")
+	if id, ok := RequestIDFromContext(ctx); !ok || !strings.HasPrefix(id, "req") {
+		t.Errorf("expected a generated request ID, got %q, %v", id, ok)
+	}
+
+	if _, ok := RequestIDFromContext(context.Background()); ok {
+		t.Error("expected no request ID")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(WithJSONOutput(), WithOutput(&buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), span)
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = NewContext(ctx, logger)
+
+	FromContext(ctx).Info("handled")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]interface{}{
+		"requestId": "req-1",
+		"traceId":   span.TraceID().String(),
+		"spanId":    span.SpanID().String(),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry[contextFieldKey]; ok {
+		t.Errorf("expected the context field to be replaced, got %v", entry)
+	}
+
+	// Without an attached logger, FromContext must not panic.
+	FromContext(context.Background()).Info("dropped")
+}
+
+func TestContextCore_Field(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(WithJSONOutput(), WithOutput(&buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-2")
+	logger.Zap().Info("handled", Context(ctx), zap.String("orderId", "o-1"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	if entry["requestId"] != "req-2" || entry["orderId"] != "o-1" {
+		t.Errorf("expected requestId and orderId, got %v", entry)
+	}
+	if _, ok := entry["traceId"]; ok {
+		t.Errorf("expected no traceId without a span, got %v", entry)
+	}
+}
+
+func TestNewContextTee_KeepsCoreLevels(t *testing.T) {
+	debugCore, debugLogs := observer.New(zap.DebugLevel)
+	infoCore, infoLogs := observer.New(zap.InfoLevel)
+	logger := zap.New(NewContextTee(debugCore, infoCore))
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.Debug("debug", Context(ctx))
+	logger.Info("info", Context(ctx))
+
+	if n := debugLogs.Len(); n != 2 {
+		t.Errorf("expected 2 entries on the debug core, got %d", n)
+	}
+	entries := infoLogs.All()
+	if len(entries) != 1 || entries[0].Message != "info" {
+		t.Fatalf("expected only the info entry on the info core, got %v", entries)
+	}
+	if id := entries[0].ContextMap()["requestId"]; id != "req-1" {
+		t.Errorf("expected requestId req-1, got %v", id)
+	}
+}
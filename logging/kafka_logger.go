@@ -3,6 +3,7 @@ package logging
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -10,20 +11,127 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	defaultBufferSize   = 10000
+	defaultBlockTimeout = 100 * time.Millisecond
+	defaultFlushTimeout = 5 * time.Second
+	defaultPurgeTimeout = time.Second
+)
+
+// KafkaCore is a zapcore.Core sending entries to a Kafka topic as LogBase
+// JSON documents, with the entry's fields nested under data.
 type KafkaCore struct {
-	producer *kafka.Producer
-	topic    string
-	encoder  zapcore.Encoder
-	level    zapcore.Level
-	appName  string
+	sink    *kafkaSink
+	topic   string
+	level   zapcore.Level
+	appName string
+	// fields are the fields added with With.
+	fields []zapcore.Field
+}
+
+type coreOptions struct {
+	configMap    kafka.ConfigMap
+	bufferSize   int
+	dropPolicy   DropPolicy
+	blockTimeout time.Duration
+	fallback     zapcore.WriteSyncer
+	flushTimeout time.Duration
+	errorOutput  zapcore.WriteSyncer
+	errorHook    func(err error, entry []byte)
 }
 
-func NewKafkaCore(brokers []string, topic string, encoder zapcore.Encoder, level zapcore.Level, appName string) (*KafkaCore, error) {
+type CoreOption func(*coreOptions)
+
+// WithKafkaConfig passes librdkafka properties, such as SASL or TLS settings,
+// to the core's producer. They override the core's defaults; kafka.ClientConfig
+// builds them from the kafka package options.
+func WithKafkaConfig(configMap kafka.ConfigMap) CoreOption {
+	return func(o *coreOptions) {
+		o.configMap = configMap
+	}
+}
+
+// WithBufferSize sets how many entries are buffered while waiting to be
+// handed to the producer, 10000 by default.
+func WithBufferSize(size int) CoreOption {
+	return func(o *coreOptions) {
+		o.bufferSize = size
+	}
+}
+
+// WithDropPolicy sets what happens to entries logged while the buffer is
+// full, DropOldest by default. blockTimeout is only used by Block.
+func WithDropPolicy(policy DropPolicy, blockTimeout time.Duration) CoreOption {
+	return func(o *coreOptions) {
+		o.dropPolicy = policy
+		o.blockTimeout = blockTimeout
+	}
+}
+
+// WithFallback sets where entries go when they cannot be handed to Kafka,
+// stderr by default. See NewRotatingFile for a local file.
+func WithFallback(sink zapcore.WriteSyncer) CoreOption {
+	return func(o *coreOptions) {
+		o.fallback = sink
+	}
+}
+
+// WithFlushTimeout bounds how long Sync and Close wait for buffered entries
+// to be delivered, 5s by default.
+func WithFlushTimeout(timeout time.Duration) CoreOption {
+	return func(o *coreOptions) {
+		o.flushTimeout = timeout
+	}
+}
+
+// WithErrorOutput sets where delivery failures and producer errors are
+// reported, stderr by default.
+func WithErrorOutput(output zapcore.WriteSyncer) CoreOption {
+	return func(o *coreOptions) {
+		o.errorOutput = output
+	}
+}
+
+// WithErrorHook calls hook for every delivery failure, with the undelivered
+// entry, and for every producer error, with a nil entry. It is called from
+// the core's event loop and must not block.
+func WithErrorHook(hook func(err error, entry []byte)) CoreOption {
+	return func(o *coreOptions) {
+		o.errorHook = hook
+	}
+}
+
+// NewKafkaCore builds a core sending entries at level or above to topic.
+//
+// Deprecated: entries always follow the LogBase schema and encoder is not
+// used. Use NewKafkaLogCore instead.
+func NewKafkaCore(brokers []string, topic string, encoder zapcore.Encoder, level zapcore.Level, appName string, opts ...CoreOption) (*KafkaCore, error) {
+	return NewKafkaLogCore(brokers, topic, level, appName, opts...)
+}
+
+// NewKafkaLogCore builds a core sending entries at level or above to topic,
+// encoded with the LogBase schema.
+func NewKafkaLogCore(brokers []string, topic string, level zapcore.Level, appName string, opts ...CoreOption) (*KafkaCore, error) {
+	options := &coreOptions{
+		bufferSize:   defaultBufferSize,
+		dropPolicy:   DropOldest,
+		blockTimeout: defaultBlockTimeout,
+		fallback:     os.Stderr,
+		flushTimeout: defaultFlushTimeout,
+		errorOutput:  os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	config := &kafka.ConfigMap{
 		"bootstrap.servers": strings.Join(brokers, ","),
 		"client.id":         "wello-go-common-logger",
 		"acks":              "1",
 	}
+	for k, v := range options.configMap {
+		(*config)[k] = v
+	}
 
 	producer, err := kafka.NewProducer(config)
 	if err != nil {
@@ -31,11 +139,10 @@ func NewKafkaCore(brokers []string, topic string, encoder zapcore.Encoder, level
 	}
 
 	return &KafkaCore{
-		producer: producer,
-		topic:    topic,
-		encoder:  encoder,
-		level:    level,
-		appName:  appName,
+		sink:    newKafkaSink(producer, options),
+		topic:   topic,
+		level:   level,
+		appName: appName,
 	}, nil
 }
 
@@ -45,10 +152,7 @@ func (kc *KafkaCore) Enabled(level zapcore.Level) bool {
 
 func (kc *KafkaCore) With(fields []zapcore.Field) zapcore.Core {
 	clone := *kc
-	clone.encoder = kc.encoder.Clone()
-	for _, field := range fields {
-		field.AddTo(clone.encoder)
-	}
+	clone.fields = append(append([]zapcore.Field(nil), kc.fields...), fields...)
 	return &clone
 }
 
@@ -59,59 +163,42 @@ func (kc *KafkaCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore
 	return ce
 }
 
+// Write buffers the entry for the producer. It never blocks, except under
+// the Block drop policy while the buffer is full.
 func (kc *KafkaCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
-	buf, err := kc.encoder.EncodeEntry(ent, fields)
-	if err != nil {
-		return err
-	}
-	defer buf.Free()
-
-	logMessage := map[string]interface{}{
-		"@timestamp": ent.Time.Format(time.RFC3339Nano),
-		"appName":    kc.appName,
-		"level":      ent.Level.String(),
-		"message":    ent.Message,
-		"caller":     ent.Caller.String(),
-	}
-
-	// Extract additional fields using a custom ObjectEncoder
-	enc := zapcore.NewMapObjectEncoder()
-	for _, f := range fields {
-		f.AddTo(enc)
-	}
-
-	// Merge extracted fields into logMessage
-	for k, v := range enc.Fields {
-		logMessage[k] = v
-	}
-
-	jsonData, err := json.Marshal(logMessage)
+	jsonData, err := json.Marshal(newLogBase(ent, kc.appName, kc.fields, fields))
 	if err != nil {
 		return fmt.Errorf("failed to marshal log message: %w", err)
 	}
 
-	msg := &kafka.Message{
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &kc.topic,
-			Partition: kafka.PartitionAny,
+	kc.sink.enqueue(&bufferedEntry{
+		level: ent.Level,
+		msg: &kafka.Message{
+			TopicPartition: kafka.TopicPartition{
+				Topic:     &kc.topic,
+				Partition: kafka.PartitionAny,
+			},
+			Value: jsonData,
+			Key:   []byte(fmt.Sprintf("%s-%s", ent.Level.String(), ent.Time.Format(time.RFC3339Nano))),
 		},
-		Value: jsonData,
-		Key:   []byte(fmt.Sprintf("%s-%s", ent.Level.String(), ent.Time.Format(time.RFC3339Nano))),
-	}
-
-	err = kc.producer.Produce(msg, nil)
-	if err != nil {
-		return fmt.Errorf("failed to produce kafka message: %w", err)
-	}
+	})
 	return nil
 }
 
+// Sync waits for the buffered entries to be delivered. It returns an error
+// when some are still outstanding after the flush timeout.
 func (kc *KafkaCore) Sync() error {
-	kc.producer.Flush(5000)
-	return nil
+	return kc.sink.sync()
 }
 
+// Stats returns the core's buffer and drop counters.
+func (kc *KafkaCore) Stats() CoreStats {
+	return kc.sink.stats()
+}
+
+// Close flushes the buffered entries and closes the producer. Entries not
+// delivered within the flush timeout are written to the fallback sink and
+// reported by the returned error.
 func (kc *KafkaCore) Close() error {
-	kc.producer.Close()
-	return nil
+	return kc.sink.close()
 }
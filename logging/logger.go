@@ -0,0 +1,203 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type loggerOptions struct {
+	appName  string
+	env      string
+	level    zapcore.Level
+	json     bool
+	output   zapcore.WriteSyncer
+	brokers  []string
+	topic    string
+	coreOpts []CoreOption
+}
+
+type LoggerOption func(*loggerOptions)
+
+// WithAppName sets the appName field of every entry.
+func WithAppName(appName string) LoggerOption {
+	return func(o *loggerOptions) {
+		o.appName = appName
+	}
+}
+
+// WithEnv sets the env field of every entry.
+func WithEnv(env string) LoggerOption {
+	return func(o *loggerOptions) {
+		o.env = env
+	}
+}
+
+// WithLevel sets the minimum level logged, info by default.
+func WithLevel(level zapcore.Level) LoggerOption {
+	return func(o *loggerOptions) {
+		o.level = level
+	}
+}
+
+// WithJSONOutput writes JSON entries instead of the human-readable console
+// format.
+func WithJSONOutput() LoggerOption {
+	return func(o *loggerOptions) {
+		o.json = true
+	}
+}
+
+// WithOutput sets where entries are written, stdout by default.
+func WithOutput(w io.Writer) LoggerOption {
+	return func(o *loggerOptions) {
+		o.output = zapcore.AddSync(w)
+	}
+}
+
+// WithKafka also sends every entry to topic through a KafkaCore.
+func WithKafka(brokers []string, topic string, opts ...CoreOption) LoggerOption {
+	return func(o *loggerOptions) {
+		o.brokers = brokers
+		o.topic = topic
+		o.coreOpts = opts
+	}
+}
+
+// ZapLogger is a zap logger satisfying Logger, so it can be passed to the
+// other packages of this module.
+type ZapLogger struct {
+	logger *zap.Logger
+	sugar  *zap.SugaredLogger
+	kafka  *KafkaCore
+}
+
+var _ Logger = (*ZapLogger)(nil)
+
+// New builds a logger writing to stdout, and to Kafka when WithKafka is set.
+// Every entry carries the appName, env, host and pid fields of LogBase, and
+// the requestId, traceId and spanId fields of the context passed with
+// WithContext or the Context field.
+func New(opts ...LoggerOption) (*ZapLogger, error) {
+	options := &loggerOptions{
+		level:  zapcore.InfoLevel,
+		output: zapcore.Lock(os.Stdout),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "@timestamp",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "message",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	var encoder zapcore.Encoder
+	if options.json {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	} else {
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+
+	cores := []zapcore.Core{zapcore.NewCore(encoder, options.output, options.level)}
+	var kafkaCore *KafkaCore
+	if options.topic != "" {
+		core, err := NewKafkaLogCore(options.brokers, options.topic, options.level, options.appName, options.coreOpts...)
+		if err != nil {
+			return nil, err
+		}
+		kafkaCore = core
+		cores = append(cores, core)
+	}
+
+	hostname, _ := os.Hostname()
+	logger := zap.New(NewContextTee(cores...), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).With(
+		zap.String("appName", options.appName),
+		zap.String("env", options.env),
+		zap.String("host", hostname),
+		zap.String("pid", strconv.Itoa(os.Getpid())),
+	)
+	return newZapLogger(logger, kafkaCore), nil
+}
+
+func newZapLogger(logger *zap.Logger, kafka *KafkaCore) *ZapLogger {
+	return &ZapLogger{
+		logger: logger,
+		// The sugared logger is called through one more frame.
+		sugar: logger.WithOptions(zap.AddCallerSkip(1)).Sugar(),
+		kafka: kafka,
+	}
+}
+
+func (l *ZapLogger) Errorf(format string, args ...interface{}) {
+	l.sugar.Errorf(format, args...)
+}
+
+func (l *ZapLogger) Infof(format string, args ...interface{}) {
+	l.sugar.Infof(format, args...)
+}
+
+func (l *ZapLogger) Debugf(format string, args ...interface{}) {
+	l.sugar.Debugf(format, args...)
+}
+
+func (l *ZapLogger) Debug(msg string, fields ...zap.Field) {
+	l.logger.Debug(msg, fields...)
+}
+
+func (l *ZapLogger) Info(msg string, fields ...zap.Field) {
+	l.logger.Info(msg, fields...)
+}
+
+func (l *ZapLogger) Warn(msg string, fields ...zap.Field) {
+	l.logger.Warn(msg, fields...)
+}
+
+func (l *ZapLogger) Error(msg string, fields ...zap.Field) {
+	l.logger.Error(msg, fields...)
+}
+
+// With returns a logger adding fields to every entry.
+func (l *ZapLogger) With(fields ...zap.Field) *ZapLogger {
+	return newZapLogger(l.logger.With(fields...), l.kafka)
+}
+
+// WithContext returns a logger adding the request ID, trace ID and span ID
+// carried by ctx to every entry.
+func (l *ZapLogger) WithContext(ctx context.Context) *ZapLogger {
+	return l.With(Context(ctx))
+}
+
+// Zap returns the underlying zap logger.
+func (l *ZapLogger) Zap() *zap.Logger {
+	return l.logger
+}
+
+// Sync flushes buffered entries.
+func (l *ZapLogger) Sync() error {
+	return l.logger.Sync()
+}
+
+// Close flushes buffered entries and closes the Kafka producer, if any. The
+// logger and those derived from it with With must not be used afterwards.
+func (l *ZapLogger) Close() error {
+	err := l.Sync()
+	if l.kafka != nil {
+		err = errors.Join(err, l.kafka.Close())
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.uber.org/zap"
+)
+
+func TestNew_JSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(WithAppName("billing"), WithEnv("staging"), WithJSONOutput(), WithOutput(&buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logger.With(zap.String("orderId", "o-1")).Infof("order %s paid", "o-1")
+	logger.Debugf("not logged at info level")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]interface{}{
+		"message": "order o-1 paid",
+		"level":   "info",
+		"appName": "billing",
+		"env":     "staging",
+		"pid":     strconv.Itoa(os.Getpid()),
+		"orderId": "o-1",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, entry[k])
+		}
+	}
+	if entry["host"] == nil {
+		t.Errorf("expected host field, got %v", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logging/logger_test.go") {
+		t.Errorf("expected the caller of Infof, got %v", entry["caller"])
+	}
+}
+
+func TestNew_Kafka(t *testing.T) {
+	cluster, err := kafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("app-logs", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+
+	var buf bytes.Buffer
+	logger, err := New(WithAppName("billing"), WithEnv("staging"), WithOutput(&buf),
+		WithKafka([]string{cluster.BootstrapServers()}, "app-logs"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Error("payment failed", zap.String("orderId", "o-1"))
+	if err := logger.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected the entry on the console output too")
+	}
+
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": cluster.BootstrapServers(),
+		"group.id":          "logger-test",
+		"auto.offset.reset": "earliest",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer consumer.Close()
+	if err := consumer.Subscribe("app-logs", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msg, err := consumer.ReadMessage(10 * time.Second)
+	if err != nil {
+		t.Fatalf("failed to read log entry: %v", err)
+	}
+
+	var entry LogBase
+	if err := json.Unmarshal(msg.Value, &entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := entry.Data.(map[string]interface{})
+	if entry.Message != "payment failed" || entry.Env != "staging" || data["orderId"] != "o-1" {
+		t.Errorf("unexpected kafka entry %s", msg.Value)
+	}
+	if entry.SchemaVersion != LogSchemaVersion || entry.Hostname == "" || entry.PID == "" {
+		t.Errorf("expected the schema version, host and pid, got %s", msg.Value)
+	}
+	if logErr, ok := entry.Error.(*LogError); !ok || logErr.Stack == "" {
+		t.Errorf("expected the stack trace of the error entry, got %s", msg.Value)
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// errorFieldKey is the key of zap.Error fields, mapped to LogBase.Error.
+const errorFieldKey = "error"
+
+// logBaseSetters fill the LogBase fields that may be set with zap fields of
+// the same key. Other fields, including those named after the remaining
+// LogBase fields, are nested under data.
+var logBaseSetters = map[string]func(*LogBase, string){
+	"appName":   func(b *LogBase, v string) { b.AppName = v },
+	"env":       func(b *LogBase, v string) { b.Env = v },
+	"requestId": func(b *LogBase, v string) { b.RequestId = v },
+	"traceId":   func(b *LogBase, v string) { b.TraceID = v },
+	"spanId":    func(b *LogBase, v string) { b.SpanID = v },
+	"service":   func(b *LogBase, v string) { b.Service = v },
+	"module":    func(b *LogBase, v string) { b.Module = v },
+	"host":      func(b *LogBase, v string) { b.Hostname = v },
+	"pid":       func(b *LogBase, v string) { b.PID = v },
+}
+
+// newLogBase maps an entry and its fields to the LogBase schema. The module
+// defaults to the logger name, and the error carries the entry's stack
+// trace when the error itself has none.
+func newLogBase(ent zapcore.Entry, appName string, fields ...[]zapcore.Field) *LogBase {
+	base := &LogBase{
+		Timestamp:     ent.Time.Format(time.RFC3339Nano),
+		AppName:       appName,
+		SchemaVersion: LogSchemaVersion,
+		Level:         ent.Level.String(),
+		Message:       ent.Message,
+		Module:        ent.LoggerName,
+	}
+	if ent.Caller.Defined {
+		base.Caller = ent.Caller.TrimmedPath()
+	}
+
+	var logErr *LogError
+	data := zapcore.NewMapObjectEncoder()
+	for _, fs := range fields {
+		for _, f := range fs {
+			if f.Key == errorFieldKey && f.Type == zapcore.ErrorType {
+				if err, ok := f.Interface.(error); ok {
+					logErr = toLogError(err)
+					continue
+				}
+			}
+			f.AddTo(data)
+		}
+	}
+	for key, set := range logBaseSetters {
+		if v, ok := data.Fields[key]; ok {
+			set(base, fieldString(v))
+			delete(data.Fields, key)
+		}
+	}
+	if len(data.Fields) > 0 {
+		base.Data = data.Fields
+	}
+
+	if ent.Stack != "" {
+		if logErr == nil {
+			logErr = &LogError{Message: ent.Message}
+		}
+		if logErr.Stack == "" {
+			logErr = &LogError{Message: logErr.Message, Type: logErr.Type, Stack: ent.Stack}
+		}
+	}
+	if logErr != nil {
+		base.Error = logErr
+	}
+	return base
+}
+
+// toLogError serializes err. Errors formatting a stack trace with %+v,
+// such as those of github.com/pkg/errors, keep it.
+func toLogError(err error) *LogError {
+	if err == nil {
+		return nil
+	}
+	if logErr, ok := err.(*LogError); ok {
+		return logErr
+	}
+	logErr := &LogError{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+	}
+	if _, ok := err.(fmt.Formatter); ok {
+		if verbose := fmt.Sprintf("%+v", err); verbose != logErr.Message {
+			logErr.Stack = verbose
+		}
+	}
+	return logErr
+}
+
+func fieldString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
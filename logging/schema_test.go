@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewLogBase(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		LoggerName: "payments",
+		Message:    "payment failed",
+		Stack:      "main.pay\n\tmain.go:10",
+	}
+	withFields := []zapcore.Field{zap.String("env", "prod"), zap.Int("pid", 42)}
+	fields := []zapcore.Field{
+		zap.Error(errors.New("card declined")),
+		zap.String("orderId", "o-1"),
+		zap.String("level", "debug"),
+		zap.String("requestId", "req-1"),
+	}
+
+	raw, err := json.Marshal(newLogBase(ent, "billing", withFields, fields))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var base LogBase
+	if err := json.Unmarshal(raw, &base); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if base.Timestamp != "2024-05-01T12:00:00Z" || base.AppName != "billing" || base.SchemaVersion != LogSchemaVersion {
+		t.Errorf("unexpected header fields %s", raw)
+	}
+	if base.Level != "error" || base.Message != "payment failed" || base.Module != "payments" {
+		t.Errorf("expected the entry's level, message and module, got %s", raw)
+	}
+	if base.Env != "prod" || base.PID != "42" || base.RequestId != "req-1" {
+		t.Errorf("expected env, pid and requestId to be promoted, got %s", raw)
+	}
+	data, _ := base.Data.(map[string]interface{})
+	if data["orderId"] != "o-1" || data["level"] != "debug" || len(data) != 2 {
+		t.Errorf("expected user fields under data, got %s", raw)
+	}
+	want := LogError{Message: "card declined", Type: "*errors.errorString", Stack: ent.Stack}
+	if logErr, ok := base.Error.(*LogError); !ok || *logErr != want {
+		t.Errorf("expected error %+v, got %s", want, raw)
+	}
+}
+
+func TestNewLogBase_StackWithoutError(t *testing.T) {
+	ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: "boom", Stack: "main.main"}
+	base := newLogBase(ent, "billing")
+	if logErr, ok := base.Error.(*LogError); !ok || logErr.Message != "boom" || logErr.Stack != "main.main" {
+		t.Errorf("expected the stack under error, got %+v", base.Error)
+	}
+	if base.Data != nil {
+		t.Errorf("expected no data, got %v", base.Data)
+	}
+}
+
+func TestLogBase_MarshalsAnyError(t *testing.T) {
+	raw, err := json.Marshal(LogBase{Message: "failed", Error: errors.New("card declined")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var base LogBase
+	if err := json.Unmarshal(raw, &base); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := LogError{Message: "card declined", Type: "*errors.errorString"}
+	if logErr, ok := base.Error.(*LogError); !ok || *logErr != want {
+		t.Errorf("expected error %+v, got %s", want, raw)
+	}
+}
@@ -1,5 +1,7 @@
 package logging
 
+import "encoding/json"
+
 // Logger 日志接口
 type Logger interface {
 	Errorf(format string, args ...interface{})
@@ -17,10 +19,15 @@ func (l *NoOpLogger) Error(format string, args ...interface{})  {}
 func (l *NoOpLogger) Info(format string, args ...interface{})   {}
 func (l *NoOpLogger) Debug(format string, args ...interface{})  {}
 
+// LogSchemaVersion is the version of the LogBase schema written by KafkaCore.
+// It changes whenever the schema changes incompatibly.
+const LogSchemaVersion = "1"
+
 type LogBase struct {
 	// required by keter
-	Timestamp string `json:"@timestamp"`
-	AppName   string `json:"appName,omitempty"`
+	Timestamp     string `json:"@timestamp"`
+	AppName       string `json:"appName,omitempty"`
+	SchemaVersion string `json:"schemaVersion,omitempty"`
 
 	// common
 	Level     string `json:"level"`
@@ -37,3 +44,40 @@ type LogBase struct {
 	Hostname  string `json:"host,omitempty"`
 	PID       string `json:"pid,omitempty"`
 }
+
+// LogError is the error of a LogBase entry as it is serialized. LogBase
+// encodes any error as a LogError and decodes its error into one.
+type LogError struct {
+	Message string `json:"message"`
+	Type    string `json:"type,omitempty"`
+	Stack   string `json:"stack,omitempty"`
+}
+
+func (e *LogError) Error() string {
+	return e.Message
+}
+
+// MarshalJSON encodes b with its error as a LogError.
+func (b LogBase) MarshalJSON() ([]byte, error) {
+	type plain LogBase
+	return json.Marshal(struct {
+		plain
+		Error *LogError `json:"error,omitempty"`
+	}{plain: plain(b), Error: toLogError(b.Error)})
+}
+
+// UnmarshalJSON decodes b, with its error as a *LogError.
+func (b *LogBase) UnmarshalJSON(data []byte) error {
+	type plain LogBase
+	v := struct {
+		*plain
+		Error *LogError `json:"error,omitempty"`
+	}{plain: (*plain)(b)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Error != nil {
+		b.Error = v.Error
+	}
+	return nil
+}
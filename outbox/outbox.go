@@ -0,0 +1,119 @@
+// Package outbox implements the transactional outbox pattern on top of Redis
+// streams: events are appended to a stream together with the business write
+// and a Relay publishes them to Kafka afterwards.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/liberty-group-tech/wello-go-common/redis"
+)
+
+// Stream entry fields.
+const (
+	fieldTopic   = "topic"
+	fieldKey     = "key"
+	fieldValue   = "value"
+	fieldHeaders = "headers"
+)
+
+// Event is a message waiting in the outbox to be published.
+type Event struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+func (e Event) values() (map[string]interface{}, error) {
+	if e.Topic == "" {
+		return nil, fmt.Errorf("outbox event topic is required")
+	}
+
+	values := map[string]interface{}{
+		fieldTopic: e.Topic,
+		fieldKey:   e.Key,
+		fieldValue: e.Value,
+	}
+	if len(e.Headers) > 0 {
+		headers, err := json.Marshal(e.Headers)
+		if err != nil {
+			return nil, err
+		}
+		values[fieldHeaders] = headers
+	}
+	return values, nil
+}
+
+func eventFromEntry(entry redis.XMessage) (Event, error) {
+	topic, _ := entry.Values[fieldTopic].(string)
+	if topic == "" {
+		return Event{}, fmt.Errorf("outbox entry %s has no topic", entry.ID)
+	}
+
+	event := Event{Topic: topic}
+	if key, _ := entry.Values[fieldKey].(string); key != "" {
+		event.Key = []byte(key)
+	}
+	value, _ := entry.Values[fieldValue].(string)
+	event.Value = []byte(value)
+	if headers, _ := entry.Values[fieldHeaders].(string); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &event.Headers); err != nil {
+			return Event{}, fmt.Errorf("outbox entry %s has malformed headers: %w", entry.ID, err)
+		}
+	}
+	return event, nil
+}
+
+// Outbox appends events to a Redis stream.
+type Outbox struct {
+	client *redis.Client
+	stream string
+}
+
+// New creates an outbox writing to stream. In a cluster, use a {hash tag} in
+// the stream name shared with the keys written by AppendTx, e.g.
+// "{orders}:outbox".
+func New(client *redis.Client, stream string) *Outbox {
+	return &Outbox{client: client, stream: stream}
+}
+
+// Append appends events to the outbox. Several events are appended
+// atomically.
+func (o *Outbox) Append(ctx context.Context, events ...Event) error {
+	if len(events) == 1 {
+		values, err := events[0].values()
+		if err != nil {
+			return err
+		}
+		_, err = o.client.XAdd(ctx, o.stream, values)
+		return err
+	}
+	return o.AppendTx(ctx, func(redis.Pipeliner) error { return nil }, events...)
+}
+
+// AppendTx runs the business write queued by fn and appends events in the
+// same MULTI/EXEC transaction, so the events are recorded if and only if the
+// write is. fn must only queue commands on pipe.
+func (o *Outbox) AppendTx(ctx context.Context, fn func(pipe redis.Pipeliner) error, events ...Event) error {
+	entries := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		values, err := event.values()
+		if err != nil {
+			return err
+		}
+		entries[i] = values
+	}
+
+	return o.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if err := fn(pipe); err != nil {
+			return err
+		}
+		for _, values := range entries {
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: o.stream, Values: values})
+		}
+		return nil
+	})
+}
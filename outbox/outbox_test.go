@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka/kafkatest"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestClient(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(redis.WithClusterOptions(&goredis.ClusterOptions{Addrs: []string{server.Addr()}}))
+	if err != nil {
+		t.Fatalf("failed to create redis client: %v", err)
+	}
+	return client, server
+}
+
+func TestOutbox_AppendTx(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+	ob := New(client, "{orders}:outbox")
+
+	err := ob.AppendTx(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "{orders}:o-1", "created", 0)
+		return nil
+	}, Event{Topic: "order.created", Key: []byte("o-1"), Value: []byte(`{"id":"o-1"}`), Headers: map[string]string{"event-type": "OrderCreated"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if v, _ := server.Get("{orders}:o-1"); v != "created" {
+		t.Errorf("expected business write to be applied, got %q", v)
+	}
+	entries, err := server.Stream("{orders}:outbox")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 outbox entry, got %v (%v)", entries, err)
+	}
+
+	if err := ob.Append(ctx, Event{Value: []byte("no topic")}); err == nil {
+		t.Error("expected error for event without topic")
+	}
+}
+
+func TestRelay_Run(t *testing.T) {
+	client, server := newTestClient(t)
+
+	cluster, err := ckafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatalf("failed to create mock cluster: %v", err)
+	}
+	defer cluster.Close()
+	if err := cluster.CreateTopic("order.created", 1, 1); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	brokers := kafka.WithBrokers([]string{cluster.BootstrapServers()})
+	producer, err := kafka.NewProducer(brokers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	ob := New(client, "outbox")
+	for _, id := range []string{"o-1", "o-2", "o-3"} {
+		if err := ob.Append(ctx, Event{Topic: "order.created", Key: []byte(id), Value: []byte(id)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	relayCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	relay := NewRelay(client, producer, "outbox", WithBlockTimeout(50*time.Millisecond), WithRetryInterval(50*time.Millisecond))
+	go func() { done <- relay.Run(relayCtx) }()
+
+	for {
+		entries, _ := server.Stream("outbox")
+		if len(entries) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("outbox was not drained, %d entries left", len(entries))
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if !server.Exists("outbox:relay-lock") {
+		t.Error("expected relay to hold the lock while running")
+	}
+
+	stop()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Exists("outbox:relay-lock") {
+		t.Error("expected relay lock to be released")
+	}
+
+	cm := kafka.NewConsumerManager(brokers, kafka.WithGroupID("outbox-test"))
+	defer cm.Close(context.Background())
+	consumer, release, err := cm.GetPool("order.created").Borrow()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+	var keys []string
+	for len(keys) < 3 {
+		msg, err := consumer.ReadMessage(10 * time.Second)
+		if err != nil {
+			t.Fatalf("failed to read relayed message: %v", err)
+		}
+		keys = append(keys, string(msg.Key))
+	}
+	if keys[0] != "o-1" || keys[1] != "o-2" || keys[2] != "o-3" {
+		t.Errorf("expected events in stream order, got %v", keys)
+	}
+}
+
+func TestRelay_RetriesFailedPublish(t *testing.T) {
+	client, server := newTestClient(t)
+	broker := kafkatest.NewBroker()
+	broker.FailNext("order.created", errors.New("broker unavailable"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ob := New(client, "outbox")
+	for _, id := range []string{"o-1", "o-2"} {
+		if err := ob.Append(ctx, Event{Topic: "order.created", Key: []byte(id), Value: []byte(id), Headers: map[string]string{"event-type": "OrderCreated"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	relayCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	relay := NewRelay(client, broker.Producer(), "outbox", WithBlockTimeout(20*time.Millisecond), WithRetryInterval(20*time.Millisecond))
+	go relay.Run(relayCtx)
+
+	msgs := broker.WaitForMessages(t, "order.created", 2, 5*time.Second)
+	if string(msgs[0].Key) != "o-1" || string(msgs[1].Key) != "o-2" {
+		t.Errorf("expected events in stream order after the failure, got %s, %s", msgs[0].Key, msgs[1].Key)
+	}
+	kafkatest.AssertHeader(t, msgs[0], "event-type", "OrderCreated")
+
+	for {
+		entries, _ := server.Stream("outbox")
+		if len(entries) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("outbox was not drained, %d entries left", len(entries))
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/liberty-group-tech/wello-go-common/internal/relay"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+)
+
+const (
+	relayGroup    = "outbox-relay"
+	relayConsumer = "relay"
+
+	defaultLockTTL       = 30 * time.Second
+	defaultBatchSize     = 100
+	defaultBlockTimeout  = time.Second
+	defaultRetryInterval = 5 * time.Second
+)
+
+type relayOptions struct {
+	lockKey       string
+	lockTTL       time.Duration
+	batchSize     int64
+	blockTimeout  time.Duration
+	retryInterval time.Duration
+	logger        logging.Logger
+}
+
+type RelayOption func(*relayOptions)
+
+// WithLockKey sets the key of the lock that elects the running relay,
+// "<stream>:relay-lock" by default.
+func WithLockKey(key string) RelayOption {
+	return func(o *relayOptions) {
+		o.lockKey = key
+	}
+}
+
+// WithLockTTL sets the expiration of the relay lock, 30s by default. The lock
+// is refreshed in the background every third of the TTL, so a crashed relay
+// is replaced within the TTL, and publishing stops as soon as a refresh
+// fails.
+func WithLockTTL(ttl time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.lockTTL = ttl
+	}
+}
+
+// WithBatchSize sets how many entries are read from the stream at once, 100
+// by default.
+func WithBatchSize(size int64) RelayOption {
+	return func(o *relayOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithBlockTimeout sets how long a read waits for new entries, 1s by default.
+func WithBlockTimeout(timeout time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.blockTimeout = timeout
+	}
+}
+
+// WithRetryInterval sets the pause before retrying after a failed publish and
+// between attempts to acquire the lock, 5s by default.
+func WithRetryInterval(interval time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.retryInterval = interval
+	}
+}
+
+func WithLogger(logger logging.Logger) RelayOption {
+	return func(o *relayOptions) {
+		o.logger = logger
+	}
+}
+
+// Relay publishes the events of an outbox stream to Kafka. Only the instance
+// holding the relay lock publishes; the others wait to take over.
+//
+// Events are published in stream order with confirmed delivery and removed
+// from the stream once delivered. Delivery is at least once: an event whose
+// delivery was confirmed but not yet acknowledged when the relay stopped is
+// published again by the next relay.
+type Relay struct {
+	client   *redis.Client
+	producer kafka.Sender
+	stream   string
+	opts     *relayOptions
+	lease    *relay.Lease
+}
+
+// NewRelay creates a relay draining stream into producer.
+func NewRelay(client *redis.Client, producer kafka.Sender, stream string, opts ...RelayOption) *Relay {
+	options := &relayOptions{
+		lockKey:       stream + ":relay-lock",
+		lockTTL:       defaultLockTTL,
+		batchSize:     defaultBatchSize,
+		blockTimeout:  defaultBlockTimeout,
+		retryInterval: defaultRetryInterval,
+		logger:        &logging.NoOpLogger{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &Relay{
+		client:   client,
+		producer: producer,
+		stream:   stream,
+		opts:     options,
+		lease: &relay.Lease{
+			Client:        client,
+			Key:           options.lockKey,
+			TTL:           options.lockTTL,
+			RetryInterval: options.retryInterval,
+			Logger:        options.logger,
+			Name:          "Outbox relay of stream " + stream,
+		},
+	}
+}
+
+// Run acquires the relay lock and drains the stream until ctx is cancelled.
+// While another instance holds the lock it retries at the retry interval.
+func (r *Relay) Run(ctx context.Context) error {
+	return r.lease.Run(ctx, r.drain)
+}
+
+// drain publishes the stream while the lock is held, until ctx is cancelled
+// or the lock is lost. Entries delivered to a previous relay but never
+// acknowledged are published first.
+func (r *Relay) drain(ctx context.Context) error {
+	if err := r.client.XGroupCreate(ctx, r.stream, relayGroup); err != nil {
+		return err
+	}
+
+	id := "0"
+	for ctx.Err() == nil {
+		entries, err := r.client.XReadGroup(ctx, r.stream, relayGroup, relayConsumer, id, r.opts.batchSize, r.opts.blockTimeout)
+		if err != nil {
+			return err
+		}
+		if id == "0" && len(entries) == 0 {
+			id = ">"
+			continue
+		}
+
+		if err := r.publish(ctx, entries); err != nil {
+			r.opts.logger.Errorf("Failed to publish outbox entries of stream %s: %v", r.stream, err)
+			id = "0"
+			relay.Sleep(ctx, r.opts.retryInterval)
+		}
+	}
+	return ctx.Err()
+}
+
+// publish sends entries in order and acknowledges those delivered. It stops
+// at the first failure so later entries are not published ahead of it.
+func (r *Relay) publish(ctx context.Context, entries []redis.XMessage) error {
+	delivered := make([]string, 0, len(entries))
+	var sendErr error
+	for _, entry := range entries {
+		event, err := eventFromEntry(entry)
+		if err != nil {
+			r.opts.logger.Errorf("Dropping outbox entry: %v", err)
+			delivered = append(delivered, entry.ID)
+			continue
+		}
+
+		if _, err := r.producer.SendSync(ctx, event.Topic, event.Value, relay.MessageOptions(event.Key, event.Headers)...); err != nil {
+			sendErr = err
+			break
+		}
+		delivered = append(delivered, entry.ID)
+	}
+
+	if len(delivered) > 0 {
+		// Delivered entries are acknowledged even when the lock was just lost.
+		if err := r.client.XAckDel(context.WithoutCancel(ctx), r.stream, relayGroup, delivered...); err != nil {
+			return errors.Join(sendErr, err)
+		}
+	}
+	return sendErr
+}
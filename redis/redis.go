@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/liberty-group-tech/wello-go-common/helper"
@@ -10,6 +11,10 @@ import (
 
 const lockPrefix = "redis-lock"
 
+// ErrLockNotHeld is returned by Lock.Refresh when the lock expired or was
+// taken over by another process.
+var ErrLockNotHeld = errors.New("redis lock is no longer held")
+
 type ClientOptions = goredis.ClusterOptions
 
 // Client wraps a redis cluster client with lazy loading support.
@@ -94,6 +99,16 @@ func (c *Client) Get(ctx context.Context, key string) (string, error) {
 	return client.Get(ctx, key).Result()
 }
 
+// SetNX stores a value at the given key with expiration only if the key does
+// not exist yet. It reports whether the value was stored.
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	client, err := c.Cluster()
+	if err != nil {
+		return false, err
+	}
+	return client.SetNX(ctx, key, value, expiration).Result()
+}
+
 // Del removes the given key.
 func (c *Client) Del(ctx context.Context, key string) error {
 	client, err := c.Cluster()
@@ -103,6 +118,16 @@ func (c *Client) Del(ctx context.Context, key string) error {
 	return client.Del(ctx, key).Err()
 }
 
+// HMGet returns the values of the given fields of the hash at key, nil for
+// missing fields.
+func (c *Client) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	client, err := c.Cluster()
+	if err != nil {
+		return nil, err
+	}
+	return client.HMGet(ctx, key, fields...).Result()
+}
+
 // Lock represents a lightweight redis distributed lock.
 type Lock struct {
 	Key        string
@@ -136,7 +161,8 @@ func (c *Client) AcquireLock(ctx context.Context, key string, expiration time.Du
 	}, nil
 }
 
-// Refresh extends the lock expiration.
+// Refresh extends the lock expiration. It returns ErrLockNotHeld when the
+// lock is no longer owned by the caller.
 func (l *Lock) Refresh(ctx context.Context, expiration time.Duration) error {
 	client, err := l.client.Cluster()
 	if err != nil {
@@ -149,7 +175,14 @@ func (l *Lock) Refresh(ctx context.Context, expiration time.Duration) error {
         return 0
     end`
 
-	return client.Eval(ctx, script, []string{l.Key}, l.token, int(expiration/time.Millisecond)).Err()
+	refreshed, err := client.Eval(ctx, script, []string{l.Key}, l.token, int(expiration/time.Millisecond)).Int()
+	if err != nil {
+		return err
+	}
+	if refreshed == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
 }
 
 // Release releases the lock if still owned by the caller.
@@ -0,0 +1,20 @@
+package redis
+
+import (
+	"context"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type Z = goredis.Z
+
+// ZRangeByScore returns up to count members of the sorted set at key whose
+// score lies between min and max, lowest score first. Bounds use the ZRANGE
+// syntax, e.g. "-inf" or "(100" for an exclusive bound.
+func (c *Client) ZRangeByScore(ctx context.Context, key string, min string, max string, count int64) ([]string, error) {
+	client, err := c.Cluster()
+	if err != nil {
+		return nil, err
+	}
+	return client.ZRangeByScore(ctx, key, &goredis.ZRangeBy{Min: min, Max: max, Count: count}).Result()
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type XMessage = goredis.XMessage
+
+type XAddArgs = goredis.XAddArgs
+
+type Pipeliner = goredis.Pipeliner
+
+type IntCmd = goredis.IntCmd
+
+// XAdd appends an entry with the given fields to stream and returns its ID.
+func (c *Client) XAdd(ctx context.Context, stream string, values map[string]interface{}) (string, error) {
+	client, err := c.Cluster()
+	if err != nil {
+		return "", err
+	}
+	return client.XAdd(ctx, &goredis.XAddArgs{Stream: stream, Values: values}).Result()
+}
+
+// XGroupCreate creates a consumer group on stream, creating the stream if
+// needed. Creating a group that already exists is not an error.
+func (c *Client) XGroupCreate(ctx context.Context, stream string, group string) error {
+	client, err := c.Cluster()
+	if err != nil {
+		return err
+	}
+	err = client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XReadGroup reads up to count entries of stream for consumer of group. Pass
+// ">" as id to read new entries, blocking up to block when there are none,
+// or "0" to read the entries delivered to consumer but not acknowledged yet.
+// It returns no entries and no error when block expires.
+func (c *Client) XReadGroup(ctx context.Context, stream string, group string, consumer string, id string, count int64, block time.Duration) ([]XMessage, error) {
+	client, err := c.Cluster()
+	if err != nil {
+		return nil, err
+	}
+
+	args := &goredis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    count,
+		Block:    block,
+	}
+	if id != ">" {
+		// XREADGROUP never blocks on pending entries.
+		args.Block = -1
+	}
+	streams, err := client.XReadGroup(ctx, args).Result()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	return streams[0].Messages, nil
+}
+
+// XAckDel acknowledges the given entries of stream for group and deletes
+// them from the stream.
+func (c *Client) XAckDel(ctx context.Context, stream string, group string, ids ...string) error {
+	client, err := c.Cluster()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.XAck(ctx, stream, group, ids...)
+		pipe.XDel(ctx, stream, ids...)
+		return nil
+	})
+	return err
+}
+
+// TxPipelined runs the commands queued by fn in a MULTI/EXEC transaction. In
+// a cluster every key of the transaction must hash to the same slot, e.g. by
+// sharing a {hash tag}.
+func (c *Client) TxPipelined(ctx context.Context, fn func(pipe Pipeliner) error) error {
+	client, err := c.Cluster()
+	if err != nil {
+		return err
+	}
+	_, err = client.TxPipelined(ctx, fn)
+	return err
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/liberty-group-tech/wello-go-common/internal/relay"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+	"github.com/liberty-group-tech/wello-go-common/logging"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+)
+
+const (
+	defaultLockTTL       = 30 * time.Second
+	defaultBatchSize     = 100
+	defaultPollInterval  = time.Second
+	defaultRetryInterval = 5 * time.Second
+)
+
+type dispatcherOptions struct {
+	lockKey       string
+	lockTTL       time.Duration
+	batchSize     int64
+	pollInterval  time.Duration
+	retryInterval time.Duration
+	logger        logging.Logger
+}
+
+type DispatcherOption func(*dispatcherOptions)
+
+// WithLockKey sets the key of the lock that elects the running dispatcher,
+// "<key>:dispatch-lock" by default.
+func WithLockKey(key string) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.lockKey = key
+	}
+}
+
+// WithLockTTL sets the expiration of the dispatcher lock, 30s by default.
+// The lock is refreshed in the background every third of the TTL, so a
+// crashed dispatcher is replaced within the TTL, and publishing stops as soon
+// as a refresh fails.
+func WithLockTTL(ttl time.Duration) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.lockTTL = ttl
+	}
+}
+
+// WithBatchSize sets how many due messages are published per poll, 100 by
+// default.
+func WithBatchSize(size int64) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithPollInterval sets how often due messages are looked up, 1s by default.
+// It bounds how late a message is published.
+func WithPollInterval(interval time.Duration) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.pollInterval = interval
+	}
+}
+
+// WithRetryInterval sets the pause before retrying after a failed publish and
+// between attempts to acquire the lock, 5s by default.
+func WithRetryInterval(interval time.Duration) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.retryInterval = interval
+	}
+}
+
+func WithLogger(logger logging.Logger) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.logger = logger
+	}
+}
+
+// Dispatcher publishes the messages of a Scheduler once they are due. Only
+// the instance holding the dispatcher lock publishes; the others wait to
+// take over.
+//
+// Due messages are published in due-time order with confirmed delivery and
+// removed once delivered. Delivery is at least once: a message delivered but
+// not yet removed when the dispatcher stopped is published again by the next
+// dispatcher.
+type Dispatcher struct {
+	client   *redis.Client
+	producer kafka.Sender
+	key      string
+	opts     *dispatcherOptions
+	lease    *relay.Lease
+}
+
+// NewDispatcher creates a dispatcher publishing the messages scheduled under
+// key with producer.
+func NewDispatcher(client *redis.Client, producer kafka.Sender, key string, opts ...DispatcherOption) *Dispatcher {
+	options := &dispatcherOptions{
+		lockKey:       key + ":dispatch-lock",
+		lockTTL:       defaultLockTTL,
+		batchSize:     defaultBatchSize,
+		pollInterval:  defaultPollInterval,
+		retryInterval: defaultRetryInterval,
+		logger:        &logging.NoOpLogger{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &Dispatcher{
+		client:   client,
+		producer: producer,
+		key:      key,
+		opts:     options,
+		lease: &relay.Lease{
+			Client:        client,
+			Key:           options.lockKey,
+			TTL:           options.lockTTL,
+			RetryInterval: options.retryInterval,
+			Logger:        options.logger,
+			Name:          "Scheduler dispatcher of " + key,
+		},
+	}
+}
+
+// Run acquires the dispatcher lock and publishes due messages until ctx is
+// cancelled. While another instance holds the lock it retries at the retry
+// interval.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	return d.lease.Run(ctx, d.poll)
+}
+
+// poll publishes due messages while the lock is held, until ctx is cancelled
+// or the lock is lost. Full batches are followed by the next one right away.
+func (d *Dispatcher) poll(ctx context.Context) error {
+	for ctx.Err() == nil {
+		n, err := d.dispatch(ctx, time.Now())
+		switch {
+		case err != nil:
+			d.opts.logger.Errorf("Failed to dispatch scheduled messages of %s: %v", d.key, err)
+			relay.Sleep(ctx, d.opts.retryInterval)
+		case int64(n) < d.opts.batchSize:
+			relay.Sleep(ctx, d.opts.pollInterval)
+		}
+	}
+	return ctx.Err()
+}
+
+// dispatch publishes the messages due at now in order and removes those
+// delivered. It stops at the first failure so later messages are not
+// published ahead of it, and returns how many messages it handled.
+func (d *Dispatcher) dispatch(ctx context.Context, now time.Time) (int, error) {
+	ids, err := d.client.ZRangeByScore(ctx, d.key, "-inf", strconv.FormatInt(now.UnixMilli(), 10), d.opts.batchSize)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	payloads, err := d.client.HMGet(ctx, messagesKey(d.key), ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	done := make([]string, 0, len(ids))
+	var sendErr error
+	for i, id := range ids {
+		payload, _ := payloads[i].(string)
+		var msg Message
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			d.opts.logger.Errorf("Dropping scheduled message %s: %v", id, err)
+			done = append(done, id)
+			continue
+		}
+
+		if _, err := d.producer.SendSync(ctx, msg.Topic, msg.Value, relay.MessageOptions(msg.Key, msg.Headers)...); err != nil {
+			sendErr = err
+			break
+		}
+		done = append(done, id)
+	}
+
+	if len(done) > 0 {
+		// Delivered messages are removed even when the lock was just lost.
+		ctx := context.WithoutCancel(ctx)
+		err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			members := make([]interface{}, len(done))
+			for i, id := range done {
+				members[i] = id
+			}
+			pipe.ZRem(ctx, d.key, members...)
+			pipe.HDel(ctx, messagesKey(d.key), done...)
+			return nil
+		})
+		if err != nil {
+			return len(done), errors.Join(sendErr, err)
+		}
+	}
+	return len(done), sendErr
+}
@@ -0,0 +1,94 @@
+// Package scheduler delays Kafka messages: messages are stored in a Redis
+// sorted set ordered by due time and a Dispatcher publishes them once due.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/liberty-group-tech/wello-go-common/helper"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+)
+
+const idPrefix = "scheduled"
+
+// Message is a message waiting to be published to Topic.
+type Message struct {
+	Topic   string            `json:"topic"`
+	Key     []byte            `json:"key,omitempty"`
+	Value   []byte            `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Scheduler stores messages until they are due.
+//
+// The IDs of the scheduled messages live in a sorted set at key, scored by
+// due time in unix milliseconds, and the messages themselves in a hash in the
+// same cluster slot, see messagesKey.
+type Scheduler struct {
+	client *redis.Client
+	key    string
+}
+
+// New creates a scheduler storing messages under key.
+func New(client *redis.Client, key string) *Scheduler {
+	return &Scheduler{client: client, key: key}
+}
+
+// messagesKey returns the key of the hash holding the messages scheduled
+// under key. Keys without a {hash tag} are wrapped in one so that both keys
+// hash to the same cluster slot.
+func messagesKey(key string) string {
+	if open := strings.IndexByte(key, '{'); open >= 0 {
+		if end := strings.IndexByte(key[open+1:], '}'); end > 0 {
+			return key + ":messages"
+		}
+	}
+	return "{" + key + "}:messages"
+}
+
+// Schedule stores msg to be published at the given time and returns its ID,
+// which can be passed to Cancel.
+func (s *Scheduler) Schedule(ctx context.Context, at time.Time, msg Message) (string, error) {
+	if msg.Topic == "" {
+		return "", fmt.Errorf("scheduled message topic is required")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	id := helper.GenerateID(idPrefix)
+	err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, messagesKey(s.key), id, data)
+		pipe.ZAdd(ctx, s.key, redis.Z{Score: float64(at.UnixMilli()), Member: id})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ScheduleAfter stores msg to be published once delay has elapsed.
+func (s *Scheduler) ScheduleAfter(ctx context.Context, delay time.Duration, msg Message) (string, error) {
+	return s.Schedule(ctx, time.Now().Add(delay), msg)
+}
+
+// Cancel removes a scheduled message. It reports whether the message was
+// still waiting, i.e. false once it has been published.
+func (s *Scheduler) Cancel(ctx context.Context, id string) (bool, error) {
+	var removed *redis.IntCmd
+	err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, s.key, id)
+		pipe.HDel(ctx, messagesKey(s.key), id)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka"
+	"github.com/liberty-group-tech/wello-go-common/kafka/kafkatest"
+	"github.com/liberty-group-tech/wello-go-common/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestClient(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client, err := redis.NewClient(redis.WithClusterOptions(&goredis.ClusterOptions{Addrs: []string{server.Addr()}}))
+	if err != nil {
+		t.Fatalf("failed to create redis client: %v", err)
+	}
+	return client, server
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	client, _ := newTestClient(t)
+	broker := kafkatest.NewBroker()
+	ctx := context.Background()
+	s := New(client, "{reminders}:scheduled")
+	now := time.Now()
+
+	msg := func(key string) Message {
+		return Message{Topic: "reminders", Key: []byte(key), Value: []byte(key), Headers: map[string]string{"event-type": "Reminder"}}
+	}
+	if _, err := s.Schedule(ctx, now.Add(-time.Minute), msg("second")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Schedule(ctx, now.Add(-time.Hour), msg("first")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Schedule(ctx, now.Add(time.Hour), msg("later")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cancelled, err := s.Schedule(ctx, now.Add(-time.Minute), msg("cancelled"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, err := s.Cancel(ctx, cancelled); err != nil || !ok {
+		t.Fatalf("expected message to be cancelled, got %v (%v)", ok, err)
+	}
+	if _, err := s.Schedule(ctx, now, Message{Value: []byte("no topic")}); err == nil {
+		t.Error("expected error for message without topic")
+	}
+
+	d := NewDispatcher(client, broker.Producer(), "{reminders}:scheduled")
+	broker.FailNext("reminders", errors.New("broker unavailable"))
+	if _, err := d.dispatch(ctx, now); err == nil {
+		t.Fatal("expected the injected send error")
+	}
+
+	n, err := d.dispatch(ctx, now)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 due messages, got %d (%v)", n, err)
+	}
+	msgs := broker.Messages("reminders")
+	if len(msgs) != 2 || string(msgs[0].Key) != "first" || string(msgs[1].Key) != "second" {
+		t.Fatalf("expected due messages in due-time order, got %v", msgs)
+	}
+	kafkatest.AssertHeader(t, msgs[0], "event-type", "Reminder")
+
+	if n, err := d.dispatch(ctx, now); err != nil || n != 0 {
+		t.Fatalf("expected published messages to be removed, got %d (%v)", n, err)
+	}
+	if n, err := d.dispatch(ctx, now.Add(2*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected the later message once due, got %d (%v)", n, err)
+	}
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	client, server := newTestClient(t)
+	broker := kafkatest.NewBroker()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := New(client, "reminders")
+	if _, err := s.ScheduleAfter(ctx, 50*time.Millisecond, Message{Topic: "reminders", Value: []byte("ping")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	d := NewDispatcher(client, broker.Producer(), "reminders", WithPollInterval(10*time.Millisecond))
+	go func() { done <- d.Run(runCtx) }()
+
+	msgs := broker.WaitForMessages(t, "reminders", 1, 5*time.Second)
+	if string(msgs[0].Value) != "ping" {
+		t.Fatalf("unexpected message %s", msgs[0].Value)
+	}
+
+	stop()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Exists("reminders:dispatch-lock") {
+		t.Error("expected dispatch lock to be released")
+	}
+}
+
+// cancellingSender cancels the dispatch context once a message is sent, as a
+// lost lock does.
+type cancellingSender struct {
+	kafka.Sender
+	cancel context.CancelFunc
+}
+
+func (s cancellingSender) SendSync(ctx context.Context, topic string, value []byte, opts ...kafka.MessageOption) (ckafka.TopicPartition, error) {
+	tp, err := s.Sender.SendSync(ctx, topic, value, opts...)
+	s.cancel()
+	return tp, err
+}
+
+func TestDispatcher_RemovesDeliveredWhenLockIsLost(t *testing.T) {
+	client, _ := newTestClient(t)
+	broker := kafkatest.NewBroker()
+	s := New(client, "{reminders}:scheduled")
+	now := time.Now()
+	for _, key := range []string{"first", "second"} {
+		if _, err := s.Schedule(context.Background(), now.Add(-time.Minute), Message{Topic: "reminders", Value: []byte(key)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(client, cancellingSender{Sender: broker.Producer(), cancel: cancel}, "{reminders}:scheduled")
+	if n, err := d.dispatch(ctx, now); !errors.Is(err, context.Canceled) || n != 1 {
+		t.Fatalf("expected one message delivered before the cancellation, got %d (%v)", n, err)
+	}
+
+	d = NewDispatcher(client, broker.Producer(), "{reminders}:scheduled")
+	if n, err := d.dispatch(context.Background(), now); err != nil || n != 1 {
+		t.Fatalf("expected only the undelivered message to be left, got %d (%v)", n, err)
+	}
+	if msgs := broker.Messages("reminders"); len(msgs) != 2 {
+		t.Errorf("expected each message to be published once, got %d", len(msgs))
+	}
+}